@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snippet as returned by the GoSnipIt api
+type snippet struct {
+	ID       int       `json:"id"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Language string    `json:"language"`
+	Author   string    `json:"author"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+	URL      string    `json:"url"`
+}
+
+type createRequest struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Language string `json:"language,omitempty"`
+	Expires  int    `json:"expires,omitempty"`
+}
+
+// error returned by the api for non 2xx responses
+type apiError struct {
+	Status  int
+	Message string            `json:"error"`
+	Fields  map[string]string `json:"fields"`
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("server responded with %d: %s", e.Status, e.Message)
+
+	// sort the field errors so the output is stable
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		msg += fmt.Sprintf("\n  %s: %s", k, e.Fields[k])
+	}
+
+	return msg
+}
+
+// thin wrapper around the GoSnipIt json api
+type client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+func newClient(cfg *config) *client {
+	return &client{
+		baseURL:    cfg.URL,
+		token:      cfg.Token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *client) create(req createRequest) (*snippet, error) {
+	s := &snippet{}
+	err := c.do(http.MethodPost, "/api/snippets", req, s)
+	return s, err
+}
+
+func (c *client) get(id int) (*snippet, error) {
+	s := &snippet{}
+	err := c.do(http.MethodGet, fmt.Sprintf("/api/snippets/%d", id), nil, s)
+	return s, err
+}
+
+func (c *client) list() ([]snippet, error) {
+	var rs struct {
+		Snippets []snippet `json:"snippets"`
+	}
+
+	err := c.do(http.MethodGet, "/api/snippets", nil, &rs)
+	return rs.Snippets, err
+}
+
+func (c *client) delete(id int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/api/snippets/%d", id), nil, nil)
+}
+
+// sends the request with body encoded as json and decodes the response into target,
+// either of them may be nil
+func (c *client) do(method, path string, body, target any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, r)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rs, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer rs.Body.Close()
+
+	if rs.StatusCode < 200 || rs.StatusCode > 299 {
+		e := &apiError{Status: rs.StatusCode}
+
+		// fall back to the status text if the body isn't a json error
+		err = json.NewDecoder(rs.Body).Decode(e)
+		if err != nil || e.Message == "" {
+			e.Message = strings.ToLower(http.StatusText(rs.StatusCode))
+		}
+
+		return e
+	}
+
+	if target == nil {
+		return nil
+	}
+
+	return json.NewDecoder(rs.Body).Decode(target)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// holds the server url and api token used to talk to a GoSnipIt instance
+type config struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+// returns the path of the config file, $XDG_CONFIG_HOME/snip/config.json on linux
+func configPath() (string, error) {
+	if path := os.Getenv("SNIP_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "snip", "config.json"), nil
+}
+
+// reads the config file if there is one, then lets the SNIP_URL and SNIP_TOKEN
+// environment variables override its values
+func loadConfig() (*config, error) {
+	cfg := &config{}
+
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if len(b) > 0 {
+		err = json.Unmarshal(b, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if url := os.Getenv("SNIP_URL"); url != "" {
+		cfg.URL = url
+	}
+
+	if token := os.Getenv("SNIP_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+
+	if cfg.URL == "" {
+		return nil, errors.New("no server url configured, set SNIP_URL or \"url\" in " + path)
+	}
+
+	if cfg.Token == "" {
+		return nil, errors.New("no api token configured, set SNIP_TOKEN or \"token\" in " + path)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,335 @@
+// Command snip is the command line client for GoSnipIt.
+//
+// It reads the server url and api token from $XDG_CONFIG_HOME/snip/config.json
+// (or the file named by SNIP_CONFIG), which can be overridden with the
+// SNIP_URL and SNIP_TOKEN environment variables.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `usage: snip <command> [flags] [args]
+
+commands:
+  create [-title T] [-lang L] [-expires 1d|7d|1y] [-json] [file ...]
+        create a snippet from each file, or from stdin if none are given
+  get [-json] <id>
+        print the content of a snippet
+  list [-json]
+        list your snippets
+  delete [-json] <id>
+        delete one of your snippets
+  open <id>
+        open a snippet in the browser
+
+Snippet ids may also be given as snippet urls.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// runs the command and returns the exit code, split out of main for testing
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	cmd, args := args[0], args[1:]
+
+	if cmd == "help" || cmd == "-h" || cmd == "--help" {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	commands := map[string]func(*client, []string, io.Reader, io.Writer) error{
+		"create": cmdCreate,
+		"get":    cmdGet,
+		"list":   cmdList,
+		"delete": cmdDelete,
+		"open":   cmdOpen,
+	}
+
+	fn, ok := commands[cmd]
+	if !ok {
+		fmt.Fprintf(stderr, "snip: unknown command %q\n\n%s", cmd, usage)
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "snip: %s\n", err)
+		return 1
+	}
+
+	err = fn(newClient(cfg), args, stdin, stdout)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+
+		fmt.Fprintf(stderr, "snip: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func cmdCreate(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	title := fs.String("title", "", "snippet title, defaults to the file name")
+	lang := fs.String("lang", "", "snippet language, defaults to the file extension")
+	expires := fs.String("expires", "7d", "time until the snippet expires: 1d, 7d or 1y")
+	asJSON := fs.Bool("json", false, "print the created snippets as json")
+
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	days, err := parseExpires(*expires)
+	if err != nil {
+		return err
+	}
+
+	var reqs []createRequest
+
+	if len(files) == 0 {
+		content, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+
+		reqs = append(reqs, createRequest{
+			Title:    orDefault(*title, "Untitled snippet"),
+			Content:  string(content),
+			Language: *lang,
+			Expires:  days,
+		})
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		reqs = append(reqs, createRequest{
+			Title:    orDefault(*title, filepath.Base(file)),
+			Content:  string(content),
+			Language: orDefault(*lang, strings.TrimPrefix(filepath.Ext(file), ".")),
+			Expires:  days,
+		})
+	}
+
+	created := []*snippet{}
+
+	for _, req := range reqs {
+		s, err := c.create(req)
+		if err != nil {
+			return err
+		}
+
+		created = append(created, s)
+
+		if !*asJSON {
+			fmt.Fprintln(stdout, s.URL)
+		}
+	}
+
+	if *asJSON {
+		// a single snippet is printed as an object, several as an array
+		if len(created) == 1 {
+			return printJSON(stdout, created[0])
+		}
+
+		return printJSON(stdout, created)
+	}
+
+	return nil
+}
+
+func cmdGet(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the snippet and its metadata as json")
+
+	id, err := parseIDArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	s, err := c.get(id)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(stdout, s)
+	}
+
+	fmt.Fprint(stdout, s.Content)
+	if !strings.HasSuffix(s.Content, "\n") {
+		fmt.Fprintln(stdout)
+	}
+
+	return nil
+}
+
+func cmdList(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the snippets as json")
+
+	_, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	snippets, err := c.list()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(stdout, snippets)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tLANGUAGE\tEXPIRES")
+
+	for _, s := range snippets {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.ID, s.Title, s.Language, s.Expires.UTC().Format("2006-01-02 15:04"))
+	}
+
+	return tw.Flush()
+}
+
+func cmdDelete(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the result as json")
+
+	id, err := parseIDArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	err = c.delete(id)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(stdout, map[string]any{"id": id, "deleted": true})
+	}
+
+	fmt.Fprintf(stdout, "deleted snippet %d\n", id)
+	return nil
+}
+
+func cmdOpen(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("open", flag.ContinueOnError)
+
+	id, err := parseIDArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/snippets/%d", c.baseURL, id)
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	return cmd.Start()
+}
+
+// parses flags that may appear before, between or after positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parses the flags and a single snippet id or url argument
+func parseIDArg(fs *flag.FlagSet, args []string) (int, error) {
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(rest) != 1 {
+		return 0, fmt.Errorf("%s expects exactly one snippet id", fs.Name())
+	}
+
+	// accept urls like https://example.com/snippets/42 as well
+	arg := strings.TrimSuffix(rest[0], "/")
+	arg = arg[strings.LastIndex(arg, "/")+1:]
+
+	id, err := strconv.Atoi(arg)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid snippet id %q", rest[0])
+	}
+
+	return id, nil
+}
+
+// converts an expiry like 1d, 7d, 1w or 1y into the number of days the server expects
+func parseExpires(value string) (int, error) {
+	days := map[string]int{
+		"1": 1, "1d": 1,
+		"7": 7, "7d": 7, "1w": 7,
+		"365": 365, "365d": 365, "1y": 365,
+	}
+
+	d, ok := days[strings.ToLower(value)]
+	if !ok {
+		return 0, fmt.Errorf("invalid expiry %q, must be one of 1d, 7d or 1y", value)
+	}
+
+	return d, nil
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+// fake api server that only knows snippet 1 and accepts the token "secret"
+func newTestAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	s := snippet{ID: 1, Title: "Hello", Content: "echo hello", Language: "sh", URL: "https://gosnipit.test/snippets/1"}
+
+	mux.HandleFunc("/api/snippets", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			var req createRequest
+			err := json.NewDecoder(r.Body).Decode(&req)
+			if err != nil || req.Title == "" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"error":"validation failed","fields":{"title":"This field cannot be blank"}}`))
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(snippet{ID: 2, Title: req.Title, Content: req.Content, Language: req.Language, URL: "https://gosnipit.test/snippets/2"})
+		default:
+			json.NewEncoder(w).Encode(map[string]any{"snippets": []snippet{s}})
+		}
+	})
+
+	mux.HandleFunc("/api/snippets/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		json.NewEncoder(w).Encode(s)
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid token"}`))
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestRun(t *testing.T) {
+	ts := newTestAPI(t)
+
+	t.Setenv("SNIP_CONFIG", t.TempDir()+"/config.json")
+	t.Setenv("SNIP_URL", ts.URL)
+	t.Setenv("SNIP_TOKEN", "secret")
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "Create from stdin",
+			args:       []string{"create", "-title", "Deploy"},
+			stdin:      "kubectl apply -f .",
+			wantCode:   0,
+			wantStdout: "https://gosnipit.test/snippets/2\n",
+		},
+		{
+			name:       "Create with flags after the file",
+			args:       []string{"create", "main_test.go", "--json"},
+			wantCode:   0,
+			wantStdout: `"language": "go"`,
+		},
+		{
+			name:       "Invalid expiry",
+			args:       []string{"create", "--expires", "2d"},
+			wantCode:   1,
+			wantStderr: `invalid expiry "2d"`,
+		},
+		{
+			name:       "Get",
+			args:       []string{"get", "1"},
+			wantCode:   0,
+			wantStdout: "echo hello\n",
+		},
+		{
+			name:       "Get by url",
+			args:       []string{"get", "--json", "https://gosnipit.test/snippets/1"},
+			wantCode:   0,
+			wantStdout: `"title": "Hello"`,
+		},
+		{
+			name:       "Get missing",
+			args:       []string{"get", "3"},
+			wantCode:   1,
+			wantStderr: "server responded with 404",
+		},
+		{
+			name:       "List",
+			args:       []string{"list"},
+			wantCode:   0,
+			wantStdout: "1   Hello",
+		},
+		{
+			name:       "Delete",
+			args:       []string{"delete", "--json", "1"},
+			wantCode:   0,
+			wantStdout: `"deleted": true`,
+		},
+		{
+			name:     "Unknown command",
+			args:     []string{"frobnicate"},
+			wantCode: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, stdout.String(), tt.wantStdout)
+			assert.StringContains(t, stderr.String(), tt.wantStderr)
+		})
+	}
+
+	t.Run("Invalid token", func(t *testing.T) {
+		t.Setenv("SNIP_TOKEN", "wrong")
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"list"}, strings.NewReader(""), &stdout, &stderr)
+
+		assert.Equal(t, code, 1)
+		assert.StringContains(t, stderr.String(), "invalid token")
+	})
+}
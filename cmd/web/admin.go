@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+// the most users listed on the admin users page
+const adminMaxUsers = 50
+
+func (app *application) adminStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.stats.Get()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Stats = stats
+
+	app.render(w, http.StatusOK, "admin.html", data)
+}
+
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+
+	users, err := app.users.Search(query, adminMaxUsers)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Roles = models.Roles
+	data.Query = query
+
+	app.render(w, http.StatusOK, "admin_users.html", data)
+}
+
+// returns the user from the url the authenticated user may moderate, otherwise it writes
+// an error response and returns nil, nobody can moderate themselves and moderators can
+// only moderate regular users
+func (app *application) moderatedUser(w http.ResponseWriter, r *http.Request) *models.User {
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return nil
+	}
+
+	role := app.authenticatedUserRole(r)
+	if user.ID == app.authenticatedUserID(r) || (role != models.RoleAdmin && user.Role != models.RoleUser) {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+
+	return user
+}
+
+func (app *application) adminUserSuspend(w http.ResponseWriter, r *http.Request) {
+	user := app.moderatedUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := app.users.SetSuspended(user.ID, true)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// authenticate would log them out anyway, this also clears their sessions page
+	err = app.sessions.DeleteAllForUser(user.ID, 0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.rememberTokens.DeleteAllForUser(user.ID, "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, user.ID, models.AuditAccountSuspend, fmt.Sprintf("by user %d", app.authenticatedUserID(r)))
+
+	app.sessionManager.Put(r.Context(), "flash", user.Name+" has been suspended")
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminUserUnsuspend(w http.ResponseWriter, r *http.Request) {
+	user := app.moderatedUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := app.users.SetSuspended(user.ID, false)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, user.ID, models.AuditAccountUnsuspend, fmt.Sprintf("by user %d", app.authenticatedUserID(r)))
+
+	app.sessionManager.Put(r.Context(), "flash", user.Name+" is no longer suspended")
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+type adminUserRoleForm struct {
+	Role string `form:"role"`
+}
+
+func (app *application) adminUserRole(w http.ResponseWriter, r *http.Request) {
+	user := app.moderatedUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var form adminUserRoleForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// the role is picked from a select, anything else is a malformed request
+	if !validator.PermittedValue(form.Role, models.Roles...) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.users.SetRole(user.ID, form.Role)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, user.ID, models.AuditRoleChange, fmt.Sprintf("%s to %s by user %d", user.Role, form.Role, app.authenticatedUserID(r)))
+
+	app.sessionManager.Put(r.Context(), "flash", user.Name+" is now a "+form.Role)
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "snippetID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	err = app.snippets.DeleteAny(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	// the owner's webhooks are notified just like when they delete it themselves
+	app.emitSnippetEvent(models.EventSnippetDeleted, snippet)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+func TestAdminRequiresRole(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t)
+
+	code, _, _ = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusForbidden)
+}
+
+func TestAdmin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "admin@example.com")
+
+	code, _, body := ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Active sessions")
+
+	code, _, body = ts.get(t, "/admin/users?q=Mocky")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "mocked@example.com")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		role     string
+		wantCode int
+	}{
+		{"Suspend user", "/admin/users/1/suspend", "", http.StatusSeeOther},
+		{"Unsuspend user", "/admin/users/6/unsuspend", "", http.StatusSeeOther},
+		{"Suspend yourself", "/admin/users/5/suspend", "", http.StatusForbidden},
+		{"Suspend non-existent user", "/admin/users/99/suspend", "", http.StatusNotFound},
+		{"Change role", "/admin/users/1/role", "moderator", http.StatusSeeOther},
+		{"Invalid role", "/admin/users/1/role", "superuser", http.StatusBadRequest},
+		{"Delete snippet", "/admin/snippets/1/delete", "", http.StatusSeeOther},
+		{"Delete non-existent snippet", "/admin/snippets/2/delete", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("role", tt.role)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+
+	// the role change is recorded with the admin who made it
+	events, err := app.auditEvents.Search(models.AuditFilter{UserID: 1, Action: models.AuditRoleChange}, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].Detail, "user to moderator by user 5")
+}
+
+func TestUserLoginSuspended(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", "suspended@example.com")
+	form.Add("password", "mocked1234")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, headers, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	_, _, body = ts.get(t, "/user/login")
+	assert.StringContains(t, body, "Your account has been suspended")
+
+	code, _, _ = ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
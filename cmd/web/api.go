@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+// snippet representation used by the json api
+type apiSnippet struct {
+	ID       int       `json:"id"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Language string    `json:"language"`
+	Author   string    `json:"author"`
+	Private  bool      `json:"private"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+	URL      string    `json:"url"`
+}
+
+func (app *application) newAPISnippet(s *models.Snippet) apiSnippet {
+	return apiSnippet{
+		ID:       s.ID,
+		Title:    s.Title,
+		Content:  s.Content,
+		Language: s.Language,
+		Author:   s.Author,
+		Private:  s.Private,
+		Created:  s.Created,
+		Expires:  s.Expires,
+		URL:      app.snippetURL(s),
+	}
+}
+
+type apiSnippetCreateRequest struct {
+	Title               string `json:"title"`
+	Content             string `json:"content"`
+	Language            string `json:"language"`
+	Expires             int    `json:"expires"`
+	Private             bool   `json:"private"`
+	validator.Validator `json:"-"`
+}
+
+// request bodies larger than this are rejected
+const apiMaxBodyBytes = 1 << 20
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !user.EmailVerified {
+		app.apiError(w, http.StatusForbidden, "verify your email address before creating snippets")
+		return
+	}
+
+	var req apiSnippetCreateRequest
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(&req)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err))
+		return
+	}
+
+	// same default as the create form
+	if req.Expires == 0 {
+		req.Expires = 7
+	}
+
+	checkSnippet(&req.Validator, req.Title, req.Content, req.Language, req.Expires)
+
+	if !req.Valid() {
+		app.writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":  "validation failed",
+			"fields": req.FieldErrors,
+		})
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	id, err := app.snippets.Insert(userID, req.Title, req.Content, req.Language, req.Expires, req.Private)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.emitSnippetEvent(models.EventSnippetCreated, snippet)
+
+	w.Header().Set("Location", fmt.Sprintf("/api/snippets/%d", snippet.ID))
+	app.writeJSON(w, http.StatusCreated, app.newAPISnippet(snippet))
+}
+
+// lists the snippets of the authenticated user, including private ones
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.OwnedBy(app.authenticatedUserID(r), 100)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	out := []apiSnippet{}
+	for _, s := range snippets {
+		out = append(out, app.newAPISnippet(s))
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippets": out})
+}
+
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, http.StatusOK, app.newAPISnippet(app.snippetFromContext(r)))
+}
+
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	err := app.deleteSnippet(snippet)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "snippet not found")
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.emitSnippetEvent(models.EventSnippetDeleted, snippet)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models/mocks"
+)
+
+// helper to make an api request to the test server with the given bearer token
+func (ts *testServer) apiRequest(t *testing.T, method, urlPath, token, body string) (int, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, string(b)
+}
+
+func TestAPISnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Missing token",
+			method:   http.MethodGet,
+			urlPath:  "/api/snippets",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"error":"missing bearer token"}`,
+		},
+		{
+			name:     "Invalid token",
+			method:   http.MethodGet,
+			urlPath:  "/api/snippets",
+			token:    "snip_nope",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "List",
+			method:   http.MethodGet,
+			urlPath:  "/api/snippets",
+			token:    mocks.MockAPIToken,
+			wantCode: http.StatusOK,
+			wantBody: `"url":"https://gosnipit.test/snippets/1"`,
+		},
+		{
+			name:     "List private",
+			method:   http.MethodGet,
+			urlPath:  "/api/snippets",
+			token:    mocks.MockAPIToken,
+			wantCode: http.StatusOK,
+			wantBody: `"url":"https://gosnipit.test/snippets/4"`,
+		},
+		{
+			name:     "View",
+			method:   http.MethodGet,
+			urlPath:  "/api/snippets/1",
+			token:    mocks.MockAPIToken,
+			wantCode: http.StatusOK,
+			wantBody: `"content":"Some mock content..."`,
+		},
+		{
+			name:     "View non-existent",
+			method:   http.MethodGet,
+			urlPath:  "/api/snippets/2",
+			token:    mocks.MockAPIToken,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Create",
+			method:   http.MethodPost,
+			urlPath:  "/api/snippets",
+			token:    mocks.MockAPIToken,
+			body:     `{"title":"Deploy","content":"make deploy","language":"make","expires":1}`,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "Create invalid",
+			method:   http.MethodPost,
+			urlPath:  "/api/snippets",
+			token:    mocks.MockAPIToken,
+			body:     `{"title":"","content":"make deploy","expires":2}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"expires":"This field must be either 1, 7 or 365"`,
+		},
+		{
+			name:     "Create malformed",
+			method:   http.MethodPost,
+			urlPath:  "/api/snippets",
+			token:    mocks.MockAPIToken,
+			body:     `{"title":`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Delete",
+			method:   http.MethodDelete,
+			urlPath:  "/api/snippets/1",
+			token:    mocks.MockAPIToken,
+			wantCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := ts.apiRequest(t, tt.method, tt.urlPath, tt.token, tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+const (
+	exportFormat  = "gosnipit-export"
+	exportVersion = 1
+
+	// limits applied when reading uploaded archives, guards against zip bombs
+	importMaxItems     = 500
+	importMaxFileBytes = 1 << 20
+	importMaxBytes     = 20 << 20 // all files of an archive together, decompressed
+)
+
+// manifest.json at the root of every export archive
+type exportManifest struct {
+	Format   string          `json:"format"`
+	Version  int             `json:"version"`
+	Exported time.Time       `json:"exported"`
+	Snippets []exportSnippet `json:"snippets"`
+}
+
+type exportSnippet struct {
+	File     string    `json:"file"` // path of the content file inside the archive
+	ID       int       `json:"id"`
+	Title    string    `json:"title"`
+	Language string    `json:"language"`
+	Private  bool      `json:"private"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+}
+
+// a single snippet read from an uploaded archive, validated before it's inserted
+type importItem struct {
+	Name     string // where the item came from, shown in the import report
+	Title    string
+	Content  string
+	Language string
+	Expires  int
+	Private  bool
+	validator.Validator
+}
+
+// outcome of importing a single item
+type importResult struct {
+	Name   string
+	Title  string
+	ID     int // id of the created snippet, 0 if the import failed
+	Errors []string
+}
+
+var slugRX = regexp.MustCompile(`[^a-z0-9]+`)
+
+// returns the file name of a snippet inside an export archive, e.g. "snippets/42-deploy-script.sh"
+func exportFileName(s *models.Snippet) string {
+	slug := strings.Trim(slugRX.ReplaceAllString(strings.ToLower(s.Title), "-"), "-")
+	if len(slug) > 50 {
+		slug = strings.TrimRight(slug[:50], "-")
+	}
+
+	ext := "txt"
+	if s.Language != "" {
+		ext = strings.ToLower(s.Language)
+	}
+
+	if slug == "" {
+		return fmt.Sprintf("snippets/%d.%s", s.ID, ext)
+	}
+
+	return fmt.Sprintf("snippets/%d-%s.%s", s.ID, slug, ext)
+}
+
+// writes a zip archive with one file per snippet and a manifest.json describing them
+func writeExport(w io.Writer, snippets []*models.Snippet) error {
+	zw := zip.NewWriter(w)
+
+	manifest := exportManifest{
+		Format:   exportFormat,
+		Version:  exportVersion,
+		Exported: time.Now().UTC(),
+		Snippets: []exportSnippet{},
+	}
+
+	for _, s := range snippets {
+		name := exportFileName(s)
+
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: s.Created,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(f, s.Content)
+		if err != nil {
+			return err
+		}
+
+		manifest.Snippets = append(manifest.Snippets, exportSnippet{
+			File:     name,
+			ID:       s.ID,
+			Title:    s.Title,
+			Language: s.Language,
+			Private:  s.Private,
+			Created:  s.Created,
+			Expires:  s.Expires,
+		})
+	}
+
+	f, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(manifest)
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// parses an uploaded export archive or gist json file into import items,
+// items that can't be read carry a non-field error instead of failing the whole import
+func readImport(data []byte, gistExpires int) ([]*importItem, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return readExportArchive(data)
+	}
+
+	return readGistExport(data, gistExpires)
+}
+
+func readExportArchive(data []byte) ([]*importItem, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	mf, ok := files["manifest.json"]
+	if !ok {
+		return nil, errors.New("the archive doesn't contain a manifest.json")
+	}
+
+	// counts the decompressed bytes of the whole archive
+	total := 0
+
+	b, err := readZipFile(mf)
+	if err != nil {
+		return nil, err
+	}
+
+	total += len(b)
+
+	var manifest exportManifest
+
+	err = json.Unmarshal(b, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest.json: %w", err)
+	}
+
+	if manifest.Format != exportFormat {
+		return nil, errors.New("the archive isn't a GoSnipIt export")
+	}
+
+	if len(manifest.Snippets) > importMaxItems {
+		return nil, fmt.Errorf("the archive contains more than %d snippets", importMaxItems)
+	}
+
+	items := []*importItem{}
+
+	// every file is read at most once, so a manifest can't decompress the same file over and over
+	read := map[string]bool{}
+
+	for _, s := range manifest.Snippets {
+		item := &importItem{
+			Name:     s.File,
+			Title:    s.Title,
+			Language: s.Language,
+			Expires:  expiryDays(s.Expires),
+			Private:  s.Private,
+		}
+
+		name := path.Clean(s.File)
+
+		f, ok := files[name]
+		switch {
+		case !ok:
+			item.AddNonFieldError("The file is missing from the archive")
+		case read[name]:
+			item.AddNonFieldError("The file is listed more than once in the manifest")
+		default:
+			read[name] = true
+
+			content, err := readZipFile(f)
+			if err != nil {
+				item.AddNonFieldError(err.Error())
+			}
+
+			total += len(content)
+			if total > importMaxBytes {
+				return nil, fmt.Errorf("the archive contains more than %d bytes of snippets", importMaxBytes)
+			}
+
+			item.Content = string(content)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// subset of a gist as returned by the github api, https://docs.github.com/en/rest/gists
+type gist struct {
+	Description string              `json:"description"`
+	Public      bool                `json:"public"` // secret gists and gists without the flag are imported as private
+	Files       map[string]gistFile `json:"files"`
+}
+
+type gistFile struct {
+	Filename string `json:"filename"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// reads a single gist or an array of gists, every file of a gist becomes its own snippet
+func readGistExport(data []byte, expires int) ([]*importItem, error) {
+	var gists []gist
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		var g gist
+
+		err := json.Unmarshal(data, &g)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gist json: %w", err)
+		}
+
+		gists = append(gists, g)
+	} else {
+		err := json.Unmarshal(data, &gists)
+		if err != nil {
+			return nil, errors.New("the file is neither a GoSnipIt export archive nor a gist json export")
+		}
+	}
+
+	items := []*importItem{}
+
+	for _, g := range gists {
+		// map order is random, keep the import report stable
+		names := make([]string, 0, len(g.Files))
+		for name := range g.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			f := g.Files[name]
+			if f.Filename == "" {
+				f.Filename = name
+			}
+
+			// single file gists are best described by their description
+			title := f.Filename
+			if len(g.Files) == 1 && strings.TrimSpace(g.Description) != "" {
+				title = strings.TrimSpace(g.Description)
+			}
+
+			items = append(items, &importItem{
+				Name:     f.Filename,
+				Title:    title,
+				Content:  f.Content,
+				Language: strings.ToLower(strings.ReplaceAll(f.Language, " ", "-")),
+				Expires:  expires,
+				Private:  !g.Public,
+			})
+		}
+	}
+
+	if len(items) > importMaxItems {
+		return nil, fmt.Errorf("the file contains more than %d snippets", importMaxItems)
+	}
+
+	return items, nil
+}
+
+// reads a file from a zip archive, refusing to decompress more than importMaxFileBytes
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer rc.Close()
+
+	b, err := io.ReadAll(io.LimitReader(rc, importMaxFileBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > importMaxFileBytes {
+		return nil, fmt.Errorf("%s is larger than %d bytes", f.Name, importMaxFileBytes)
+	}
+
+	return b, nil
+}
+
+// maps an absolute expiry time to the smallest supported expiry option that
+// doesn't cut the remaining lifetime short, or 0 if it already expired
+func expiryDays(expires time.Time) int {
+	remaining := time.Until(expires)
+	if remaining <= 0 {
+		return 0
+	}
+
+	for _, days := range []int{1, 7, 365} {
+		if remaining <= time.Duration(days)*24*time.Hour {
+			return days
+		}
+	}
+
+	return 365
+}
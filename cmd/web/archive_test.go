@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	snippets := []*models.Snippet{
+		{
+			ID:       1,
+			Title:    "Deploy script",
+			Content:  "make deploy",
+			Language: "sh",
+			Private:  true,
+			Created:  time.Now().Add(-time.Hour),
+			Expires:  time.Now().Add(3 * 24 * time.Hour),
+		},
+		{
+			ID:      2,
+			Title:   "Gone",
+			Content: "old",
+			Expires: time.Now().Add(-time.Hour),
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	err := writeExport(buf, snippets)
+	assert.NilError(t, err)
+
+	items, err := readImport(buf.Bytes(), 365)
+	assert.NilError(t, err)
+	assert.Equal(t, len(items), 2)
+
+	assert.Equal(t, items[0].Name, "snippets/1-deploy-script.sh")
+	assert.Equal(t, items[0].Title, "Deploy script")
+	assert.Equal(t, items[0].Content, "make deploy")
+	assert.Equal(t, items[0].Language, "sh")
+	assert.Equal(t, items[0].Private, true)
+	assert.Equal(t, items[1].Private, false)
+	// three days left round up to the one week option
+	assert.Equal(t, items[0].Expires, 7)
+
+	// already expired snippets are flagged with a zero expiry
+	assert.Equal(t, items[1].Expires, 0)
+}
+
+func TestReadGistExport(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantErr     bool
+		wantTitle   []string
+		wantPrivate []bool
+	}{
+		{
+			name:        "Single gist",
+			data:        `{"description":"Nginx config","public":true,"files":{"nginx.conf":{"filename":"nginx.conf","language":"Nginx","content":"server {}"}}}`,
+			wantTitle:   []string{"Nginx config"},
+			wantPrivate: []bool{false},
+		},
+		{
+			name: "Secret gist list",
+			data: `[{"description":"Scripts","public":false,"files":{
+				"b.sh":{"filename":"b.sh","language":"Shell","content":"echo b"},
+				"a.sh":{"filename":"a.sh","language":"Shell","content":"echo a"}}}]`,
+			wantTitle:   []string{"a.sh", "b.sh"},
+			wantPrivate: []bool{true, true},
+		},
+		{
+			name:    "Not a gist",
+			data:    `hello`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := readImport([]byte(tt.data), 7)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, len(items), len(tt.wantTitle))
+
+			for i, title := range tt.wantTitle {
+				assert.Equal(t, items[i].Title, title)
+				assert.Equal(t, items[i].Expires, 7)
+				assert.Equal(t, items[i].Private, tt.wantPrivate[i])
+			}
+		})
+	}
+}
+
+// returns an export archive whose manifest lists the given files, each file holds size bytes
+func zipBomb(t *testing.T, files []string, size int) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	manifest := exportManifest{Format: exportFormat, Version: exportVersion}
+
+	for _, name := range files {
+		manifest.Snippets = append(manifest.Snippets, exportSnippet{File: name, Title: name, Expires: time.Now().Add(time.Hour)})
+	}
+
+	written := map[string]bool{}
+	for _, name := range files {
+		if written[name] {
+			continue
+		}
+		written[name] = true
+
+		f, err := zw.Create(name)
+		assert.NilError(t, err)
+		_, err = f.Write(bytes.Repeat([]byte("a"), size))
+		assert.NilError(t, err)
+	}
+
+	f, err := zw.Create("manifest.json")
+	assert.NilError(t, err)
+	assert.NilError(t, json.NewEncoder(f).Encode(manifest))
+	assert.NilError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func TestReadExportArchiveLimits(t *testing.T) {
+	// the same file listed over and over is only read once
+	items, err := readImport(zipBomb(t, []string{"a.txt", "a.txt", "a.txt"}, importMaxFileBytes), 7)
+	assert.NilError(t, err)
+	assert.Equal(t, len(items), 3)
+	assert.Equal(t, len(items[0].Content), importMaxFileBytes)
+	assert.Equal(t, items[1].Content, "")
+	assert.Equal(t, items[1].NonFieldErrors[0], "The file is listed more than once in the manifest")
+
+	// distinct files count towards the limit of the whole archive
+	files := []string{}
+	for i := 0; i <= importMaxBytes/importMaxFileBytes; i++ {
+		files = append(files, fmt.Sprintf("%d.txt", i))
+	}
+
+	_, err = readImport(zipBomb(t, files, importMaxFileBytes), 7)
+	assert.Equal(t, err != nil && strings.Contains(err.Error(), "bytes of snippets"), true)
+}
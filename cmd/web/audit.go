@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+const (
+	// the most events shown on the security page and in the admin audit log
+	auditMaxEvents = 100
+	// upper bound for the number of events in a single export
+	auditExportMaxEvents = 100000
+)
+
+// records a security event caused by the request, failures are logged instead of
+// failing the request, the action already happened at this point
+func (app *application) audit(r *http.Request, userID int, action, detail string) {
+	err := app.auditEvents.Insert(&models.AuditEvent{
+		UserID:    userID,
+		Action:    action,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		Detail:    detail,
+	})
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+func (app *application) accountSecurity(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	events, err := app.auditEvents.ForUser(id, auditMaxEvents)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.AuditEvents = events
+
+	app.render(w, http.StatusOK, "security.html", data)
+}
+
+type auditFilterForm struct {
+	User                string `form:"user"` // email address or username
+	Action              string `form:"action"`
+	IP                  string `form:"ip"`
+	Since               string `form:"since"` // dates in the 2006-01-02 format
+	Until               string `form:"until"`
+	validator.Validator `form:"-"`
+}
+
+// decodes and checks the filters in the query string, the returned filter is only
+// meaningful if the form is valid
+func (app *application) decodeAuditFilter(r *http.Request) (auditFilterForm, models.AuditFilter, error) {
+	var form auditFilterForm
+	var filter models.AuditFilter
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		form.AddNonFieldError("Invalid filters")
+		return form, filter, nil
+	}
+
+	form.User = strings.TrimSpace(form.User)
+	form.IP = strings.TrimSpace(form.IP)
+
+	if form.User != "" {
+		user, err := app.userByEmailOrUsername(form.User)
+		switch {
+		case err == nil:
+			filter.UserID = user.ID
+		case errors.Is(err, models.ErrNoRecord):
+			form.AddFieldError("user", "No user with this email address or username")
+		default:
+			return form, filter, err
+		}
+	}
+
+	if form.Action != "" {
+		form.CheckField(validator.PermittedValue(form.Action, models.AuditActions...), "action", "Unknown action")
+		filter.Action = form.Action
+	}
+
+	if form.IP != "" {
+		form.CheckField(net.ParseIP(form.IP) != nil, "ip", "This field must be an IP address")
+		filter.IP = form.IP
+	}
+
+	if form.Since != "" {
+		filter.Since, err = time.Parse("2006-01-02", form.Since)
+		form.CheckField(err == nil, "since", "This field must be a date")
+	}
+
+	// the until date is inclusive
+	if form.Until != "" {
+		filter.Until, err = time.Parse("2006-01-02", form.Until)
+		form.CheckField(err == nil, "until", "This field must be a date")
+		filter.Until = filter.Until.AddDate(0, 0, 1)
+	}
+
+	return form, filter, nil
+}
+
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+	form, filter, err := app.decodeAuditFilter(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.AuditActions = models.AuditActions
+
+	if !form.Valid() {
+		app.render(w, http.StatusUnprocessableEntity, "admin_audit.html", data)
+		return
+	}
+
+	data.AuditEvents, err = app.auditEvents.Search(filter, auditMaxEvents)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, http.StatusOK, "admin_audit.html", data)
+}
+
+// audit event representation used by the json lines export
+type auditExportEvent struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id,omitempty"`
+	UserEmail string    `json:"user_email,omitempty"`
+	Action    string    `json:"action"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Detail    string    `json:"detail,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+// exports the events matching the filters as json lines, one event per line
+func (app *application) adminAuditExport(w http.ResponseWriter, r *http.Request) {
+	form, filter, err := app.decodeAuditFilter(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	events, err := app.auditEvents.Search(filter, auditExportMaxEvents)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// build the export in memory first so errors can still result in a 500
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+
+	for _, e := range events {
+		err = enc.Encode(auditExportEvent{
+			ID:        e.ID,
+			UserID:    e.UserID,
+			UserEmail: e.UserEmail,
+			Action:    e.Action,
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Detail:    e.Detail,
+			Created:   e.Created.UTC(),
+		})
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("gosnipit-audit-%s.jsonl", time.Now().UTC().Format("2006-01-02"))
+
+	w.Header().Set("Content-Type", "application/jsonl")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	buf.WriteTo(w)
+}
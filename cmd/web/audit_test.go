@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models/mocks"
+)
+
+func TestAuditEvents(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "mocked@example.com")
+	form.Add("password", "wrongpassword")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/account/tokens")
+	csrfToken := extractCSRFToken(t, body)
+
+	form = url.Values{}
+	form.Add("name", "laptop")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, "/account/tokens", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, body = ts.get(t, "/account/security")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "token_create (laptop)")
+	assert.StringContains(t, body, "login_failed (mocked@example.com)")
+
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, "/user/logout", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	actions := app.auditEvents.(*mocks.AuditModel).Actions()
+	assert.Equal(t, strings.Join(actions, ","), "login_failed,login,token_create,logout")
+}
+
+func TestAdminAudit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// regular users can't see the audit log
+	ts.login(t)
+
+	code, _, _ := ts.get(t, "/admin/audit")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	ts.loginAs(t, "admin@example.com")
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{"No filters", "", http.StatusOK, "mocked@example.com"},
+		{"User filter", "?user=admin-mock&action=login", http.StatusOK, "admin@example.com"},
+		{"Unknown user", "?user=nobody@example.com", http.StatusUnprocessableEntity, "No user with this email address or username"},
+		{"Unknown action", "?action=explode", http.StatusUnprocessableEntity, "Unknown action"},
+		{"Invalid IP", "?ip=localhost", http.StatusUnprocessableEntity, "This field must be an IP address"},
+		{"Invalid date", "?since=yesterday", http.StatusUnprocessableEntity, "This field must be a date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, "/admin/audit"+tt.query)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	code, headers, body := ts.get(t, "/admin/audit/export?action=login")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/jsonl")
+
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	assert.Equal(t, len(lines), 2)
+
+	for _, line := range lines {
+		var e auditExportEvent
+
+		err := json.Unmarshal([]byte(line), &e)
+		assert.NilError(t, err)
+		assert.Equal(t, e.Action, "login")
+	}
+
+	code, _, _ = ts.get(t, "/admin/audit/export?since=yesterday")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
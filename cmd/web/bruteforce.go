@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// failures are tracked by the submitted email address whether an account exists
+// for it or not, so throttling doesn't reveal which addresses are registered
+func accountFailureKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// failed 2fa codes count against the account that passed the password step
+func twoFactorFailureKey(userID int) string {
+	return fmt.Sprintf("2fa:%d", userID)
+}
+
+// returns how long the client has to wait before it may try to log in to the account again,
+// checked before the password so throttled attempts don't cost a password hash comparison
+func (app *application) loginWait(r *http.Request, accountKey string) time.Duration {
+	wait := app.accountFailures.Wait(accountKey)
+
+	if ipWait := app.ipFailures.Wait("ip:" + clientIP(r)); ipWait > wait {
+		wait = ipWait
+	}
+
+	return wait
+}
+
+// records a failed login attempt for the account and ip address,
+// reports whether the account got locked out because of it
+func (app *application) loginFailed(r *http.Request, accountKey string) bool {
+	app.ipFailures.Fail("ip:" + clientIP(r))
+
+	return app.accountFailures.Fail(accountKey)
+}
+
+// stores the lockout of the account so its owner can see it on the sessions page
+func (app *application) recordLockout(r *http.Request, userID int, accountKey string) error {
+	until := time.Now().Add(app.accountFailures.Wait(accountKey))
+
+	return app.loginLockouts.Insert(userID, clientIP(r), until)
+}
+
+// the same message is used for delays and lockouts, whether the account exists or not
+func throttledLoginMessage(wait time.Duration) string {
+	n, unit := int(math.Ceil(wait.Seconds())), "second"
+	if wait > time.Minute {
+		n, unit = int(math.Ceil(wait.Minutes())), "minute"
+	}
+
+	if n != 1 {
+		unit += "s"
+	}
+
+	return fmt.Sprintf("Too many failed login attempts, please try again in %d %s", n, unit)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/justinas/nosurf"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+// the longest comment accepted, in characters
+const commentMaxChars = 2000
+
+type commentForm struct {
+	Body                string `form:"body"`
+	Line                int    `form:"line"`   // 0 to comment on the whole snippet
+	ParentID            int    `form:"parent"` // 0 to start a new thread
+	validator.Validator `form:"-"`
+}
+
+// comment as rendered on the snippet page
+type commentView struct {
+	*models.Comment
+	CanEdit   bool
+	CanDelete bool
+}
+
+// a top-level comment followed by its replies, rendered by the "thread" template in view.html,
+// which can't reach the page data so the thread carries what its forms need
+type commentThread struct {
+	Comments  []commentView
+	SnippetID int
+	CSRFToken string
+	CanReply  bool
+}
+
+// a line of the snippet content along with the threads about it
+type snippetLine struct {
+	Number  int
+	Text    string
+	Threads []*commentThread
+}
+
+// splits the content into numbered lines, a trailing newline doesn't start another line
+func snippetLines(content string) []snippetLine {
+	content = strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	lines := []snippetLine{}
+	for i, text := range strings.Split(content, "\n") {
+		lines = append(lines, snippetLine{Number: i + 1, Text: text})
+	}
+
+	return lines
+}
+
+// groups the comments on the snippet into threads and attaches those about a line to it,
+// returns the threads about the whole snippet, threads about lines that no longer exist
+// after an edit are among them
+func (app *application) attachComments(r *http.Request, snippet *models.Snippet, lines []snippetLine) ([]*commentThread, error) {
+	comments, err := app.comments.ForSnippet(snippet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := app.authenticatedUserID(r)
+	isOwner := app.snippetAccessFromContext(r) == models.AccessOwner
+
+	threads := map[int]*commentThread{}
+	general := []*commentThread{}
+
+	// comments come oldest first, so a thread always exists before its replies
+	for _, c := range comments {
+		isAuthor := userID != 0 && c.UserID == userID
+		view := commentView{Comment: c, CanEdit: isAuthor, CanDelete: !c.Deleted && (isAuthor || isOwner)}
+
+		if c.ParentID != 0 {
+			if thread, ok := threads[c.ParentID]; ok {
+				thread.Comments = append(thread.Comments, view)
+			}
+
+			continue
+		}
+
+		thread := &commentThread{
+			Comments:  []commentView{view},
+			SnippetID: snippet.ID,
+			CSRFToken: nosurf.Token(r),
+			CanReply:  userID != 0,
+		}
+		threads[c.ID] = thread
+
+		if c.Line > 0 && c.Line <= len(lines) {
+			lines[c.Line-1].Threads = append(lines[c.Line-1].Threads, thread)
+		} else {
+			general = append(general, thread)
+		}
+	}
+
+	return general, nil
+}
+
+// loads the comment from the url param, it has to belong to the snippet loaded by requireSnippetAccess
+func (app *application) snippetComment(w http.ResponseWriter, r *http.Request) *models.Comment {
+	id, err := strconv.Atoi(chi.URLParam(r, "commentID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	comment, err := app.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return nil
+	}
+
+	if comment.SnippetID != app.snippetFromContext(r).ID {
+		app.notFound(w)
+		return nil
+	}
+
+	return comment
+}
+
+func (app *application) commentCreate(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	var form commentForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Body), "body", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Body, commentMaxChars), "body", fmt.Sprintf("This field cannot be longer than %d characters", commentMaxChars))
+
+	if form.ParentID != 0 {
+		parent, err := app.comments.Get(form.ParentID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.clientError(w, http.StatusBadRequest)
+			} else {
+				app.serverError(w, err)
+			}
+
+			return
+		}
+
+		if parent.SnippetID != snippet.ID {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		// threads are one level deep, replies to a reply go to the thread it belongs to
+		if parent.ParentID != 0 {
+			form.ParentID = parent.ParentID
+		}
+
+		form.Line = parent.Line
+	} else {
+		lines := len(snippetLines(snippet.Content))
+		form.CheckField(form.Line >= 0 && form.Line <= lines, "line", fmt.Sprintf("This field must be a line between 1 and %d", lines))
+	}
+
+	if !form.Valid() {
+		app.renderSnippet(w, r, http.StatusUnprocessableEntity, shareCreateForm{Access: models.AccessRead}, form)
+		return
+	}
+
+	id, err := app.comments.Insert(snippet.ID, app.authenticatedUserID(r), form.ParentID, form.Line, form.Body)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment posted")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d#comment-%d", snippet.ID, id), http.StatusSeeOther)
+}
+
+func (app *application) commentEditForm(w http.ResponseWriter, r *http.Request) {
+	comment := app.snippetComment(w, r)
+	if comment == nil {
+		return
+	}
+
+	if comment.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = app.snippetFromContext(r)
+	data.Comment = comment
+	data.Form = commentForm{Body: comment.Body}
+	app.render(w, http.StatusOK, "comment_edit.html", data)
+}
+
+func (app *application) commentEdit(w http.ResponseWriter, r *http.Request) {
+	comment := app.snippetComment(w, r)
+	if comment == nil {
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	if comment.UserID != userID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	var form commentForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Body), "body", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Body, commentMaxChars), "body", fmt.Sprintf("This field cannot be longer than %d characters", commentMaxChars))
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = app.snippetFromContext(r)
+		data.Comment = comment
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "comment_edit.html", data)
+		return
+	}
+
+	err = app.comments.Update(comment.ID, userID, form.Body)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment updated")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d#comment-%d", comment.SnippetID, comment.ID), http.StatusSeeOther)
+}
+
+// deletes a comment, the replies to it are kept, comments can be deleted by their author and the snippet author
+func (app *application) commentDelete(w http.ResponseWriter, r *http.Request) {
+	comment := app.snippetComment(w, r)
+	if comment == nil {
+		return
+	}
+
+	isAuthor := comment.UserID != 0 && comment.UserID == app.authenticatedUserID(r)
+	if !isAuthor && app.snippetAccessFromContext(r) != models.AccessOwner {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := app.comments.Delete(comment.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment deleted")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", comment.SnippetID), http.StatusSeeOther)
+}
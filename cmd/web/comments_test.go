@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+func TestCommentCreate(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		urlPath      string
+		body         string
+		line         string
+		parent       string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{"Whole snippet", "mocked@example.com", "/snippets/1/comments", "Nice", "", "", http.StatusSeeOther, "/snippets/1#comment-6", ""},
+		{"Line", "mocked@example.com", "/snippets/1/comments", "Nice", "1", "", http.StatusSeeOther, "/snippets/1#comment-6", ""},
+		{"Reply", "admin@example.com", "/snippets/1/comments", "Fair enough", "", "3", http.StatusSeeOther, "/snippets/1#comment-6", ""},
+		{"Shared snippet", "unverified@example.com", "/snippets/4/comments", "Nice", "", "", http.StatusSeeOther, "/snippets/4#comment-6", ""},
+		{"Blank body", "mocked@example.com", "/snippets/1/comments", "  ", "", "", http.StatusUnprocessableEntity, "", "This field cannot be blank"},
+		{"Long body", "mocked@example.com", "/snippets/1/comments", strings.Repeat("a", 2001), "", "", http.StatusUnprocessableEntity, "", "This field cannot be longer than 2000 characters"},
+		{"Line out of range", "mocked@example.com", "/snippets/1/comments", "Nice", "2", "", http.StatusUnprocessableEntity, "", "This field must be a line between 1 and 1"},
+		{"Negative line", "mocked@example.com", "/snippets/1/comments", "Nice", "-1", "", http.StatusUnprocessableEntity, "", "This field must be a line between 1 and 1"},
+		{"Missing parent", "mocked@example.com", "/snippets/1/comments", "Nice", "", "9", http.StatusBadRequest, "", ""},
+		{"Parent on other snippet", "mocked@example.com", "/snippets/4/comments", "Nice", "", "1", http.StatusBadRequest, "", ""},
+		{"Hidden snippet", "unverified@example.com", "/snippets/3/comments", "Nice", "", "", http.StatusNotFound, "", ""},
+		{"Anonymous", "", "/snippets/1/comments", "Nice", "", "", http.StatusSeeOther, "/user/login", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.loginAs(t, tt.email)
+			}
+
+			_, _, body := ts.get(t, "/user/login")
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("line", tt.line)
+			form.Add("parent", tt.parent)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, header, body := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCommentEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		body     string
+		wantCode int
+	}{
+		{"Author", "admin@example.com", "/snippets/1/comments/2/edit", "Why this line exactly?", http.StatusSeeOther},
+		{"Blank body", "admin@example.com", "/snippets/1/comments/2/edit", "", http.StatusUnprocessableEntity},
+		{"Not the author", "admin@example.com", "/snippets/1/comments/1/edit", "Edited", http.StatusForbidden},
+		{"Snippet author", "mocked@example.com", "/snippets/1/comments/2/edit", "Edited", http.StatusForbidden},
+		{"Wrong snippet", "mocked@example.com", "/snippets/4/comments/1/edit", "Edited", http.StatusNotFound},
+		{"Missing comment", "mocked@example.com", "/snippets/1/comments/9/edit", "Edited", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			code, _, body := ts.get(t, tt.urlPath)
+			if tt.wantCode != http.StatusSeeOther && tt.wantCode != http.StatusUnprocessableEntity {
+				assert.Equal(t, code, tt.wantCode)
+				return
+			}
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, "Why this line?</textarea>")
+
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusSeeOther {
+				assert.Equal(t, header.Get("Location"), "/snippets/1#comment-2")
+			}
+		})
+	}
+}
+
+func TestCommentDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{"Author", "admin@example.com", "/snippets/1/comments/2/delete", http.StatusSeeOther},
+		{"Snippet author", "mocked@example.com", "/snippets/1/comments/2/delete", http.StatusSeeOther},
+		{"Neither", "admin@example.com", "/snippets/1/comments/1/delete", http.StatusForbidden},
+		{"Wrong snippet", "mocked@example.com", "/snippets/4/comments/1/delete", http.StatusNotFound},
+		{"Missing comment", "mocked@example.com", "/snippets/1/comments/9/delete", http.StatusNotFound},
+		{"Already deleted", "mocked@example.com", "/snippets/1/comments/4/delete", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			_, _, body := ts.get(t, "/user/login")
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusSeeOther {
+				assert.Equal(t, header.Get("Location"), "/snippets/1")
+			}
+		})
+	}
+}
+
+func TestCommentsView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// anonymous readers see the discussion but can't take part
+	code, _, body := ts.get(t, "/snippets/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Looks good to me")
+	assert.Equal(t, strings.Contains(body, `action="/snippets/1/comments"`), false)
+	assert.Equal(t, strings.Contains(body, "/comments/2/delete"), false)
+
+	// line comments and their replies come right after the line
+	line := strings.Index(body, "<pre id='L1'>")
+	thread := strings.Index(body, "Why this line?")
+	reply := strings.Index(body, "Because it works")
+	general := strings.Index(body, "Looks good to me")
+	assert.Equal(t, line < thread && thread < reply && reply < general, true)
+
+	// the snippet author can delete any comment but only edit their own
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/snippets/1")
+	assert.StringContains(t, body, `<form action="/snippets/1/comments/2/delete" method="post">`)
+	assert.StringContains(t, body, `<a href="/snippets/1/comments/1/edit">Edit</a>`)
+	assert.Equal(t, strings.Contains(body, "/comments/2/edit"), false)
+	assert.StringContains(t, body, `<input type="hidden" name="parent" value="2">`)
+
+	// a deleted comment keeps its place in the thread, without an author or any actions
+	assert.StringContains(t, body, "[deleted]")
+	assert.StringContains(t, body, "Still worth a read")
+	assert.Equal(t, strings.Contains(body, "/comments/4/delete"), false)
+	assert.StringContains(t, body, `<input type="hidden" name="parent" value="4">`)
+
+	// comments are escaped
+	_, _, body = ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("body", "<script>alert(1)</script>")
+	form.Add("line", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body = ts.postForm(t, "/snippets/1/comments", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	assert.Equal(t, strings.Contains(body, "<script>alert(1)</script>"), false)
+}
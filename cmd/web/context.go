@@ -3,6 +3,10 @@ package main
 type contextKey string
 
 const (
-	isAuthenticatedContextKey = contextKey("isAuthenticated")
-	snippetContextKey         = contextKey("snippet")
+	isAuthenticatedContextKey     = contextKey("isAuthenticated")
+	authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+	sessionIDContextKey           = contextKey("sessionID")
+	userRoleContextKey            = contextKey("userRole")
+	snippetContextKey             = contextKey("snippet")
+	snippetAccessContextKey       = contextKey("snippetAccess")
 )
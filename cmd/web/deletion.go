@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+type accountDeleteForm struct {
+	Password            string `form:"password"`
+	Mode                string `form:"mode"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountDeleteForm(w http.ResponseWriter, r *http.Request) {
+	data, err := app.newDeleteTemplateData(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data.Form = accountDeleteForm{Mode: models.DeletionModeDelete}
+	app.render(w, http.StatusOK, "delete.html", data)
+}
+
+func (app *application) newDeleteTemplateData(r *http.Request) (*templateData, error) {
+	data := app.newTemplateData(r)
+	data.DeletionDate = app.deletionDate()
+	data.SSOConfirmed = app.ssoConfirmed(r)
+
+	if app.sso != nil {
+		linked, err := app.identities.Linked(app.authenticatedUserID(r))
+		if err != nil {
+			return nil, err
+		}
+
+		data.SSOLinked = linked
+	}
+
+	return data, nil
+}
+
+// accounts created with sso have a random password, their owners confirm the
+// deletion by logging in with the provider again
+func (app *application) accountDeleteSSO(w http.ResponseWriter, r *http.Request) {
+	app.redirectToSSO(w, r, true)
+}
+
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	var form accountDeleteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// a recent confirmation with the sso provider replaces the password
+	confirmed := app.ssoConfirmed(r)
+
+	if !confirmed {
+		form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	}
+	form.CheckField(validator.PermittedValue(form.Mode, models.DeletionModeDelete, models.DeletionModeAnonymize), "mode", "This field must equal delete or anonymize")
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	if form.Valid() && !confirmed {
+		err = app.users.CheckPassword(id, form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return
+			}
+
+			form.AddFieldError("password", "Invalid password")
+		}
+	}
+
+	if !form.Valid() {
+		data, err := app.newDeleteTemplateData(r)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "delete.html", data)
+		return
+	}
+
+	err = app.users.ScheduleDeletion(id, form.Mode, time.Now().Add(app.deletionGrace))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	flash := "Your account has been deleted"
+
+	app.audit(r, id, models.AuditAccountDeletion, "")
+
+	if app.deletionGrace == 0 {
+		err = app.purgeAccount(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	} else {
+		// log out everywhere, logging in again is how the deletion gets cancelled
+		err = app.sessions.DeleteAllForUser(id, 0)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		err = app.rememberTokens.DeleteAllForUser(id, "")
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		// the api doesn't go through the login, so the tokens would keep working until the purge
+		err = app.apiTokens.DeleteAllForUser(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		flash = fmt.Sprintf("Your account will be deleted on %s, log in before then if you change your mind",
+			humanDate(app.deletionDate()))
+	}
+
+	clearRememberCookie(w)
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "sessionToken")
+	app.sessionManager.Remove(r.Context(), "ssoConfirmedAt")
+	app.sessionManager.Put(r.Context(), "flash", flash)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// returns when an account deleted now would be removed, zero if it's removed right away
+func (app *application) deletionDate() time.Time {
+	if app.deletionGrace == 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(app.deletionGrace)
+}
+
+// deletes the accounts whose grace period ran out, every interval
+func (app *application) runAccountDeletionWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.deleteDueAccounts()
+	}
+}
+
+func (app *application) deleteDueAccounts() {
+	ids, err := app.users.DueDeletions()
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	for _, id := range ids {
+		err = app.purgeAccount(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.errorLog.Print(err)
+			continue
+		}
+
+		app.infoLog.Printf("Deleted account %d", id)
+	}
+}
+
+// removes the account, when its snippets go along with it their deletion is sent to the
+// user's webhooks first, the webhooks are removed with the account so pending deliveries
+// get a single attempt right away instead of waiting for the webhook worker
+func (app *application) purgeAccount(id int) error {
+	mode, err := app.users.DeletionMode(id)
+	if err != nil {
+		return err
+	}
+
+	if mode == models.DeletionModeDelete {
+		snippets, err := app.snippets.OwnedBy(id, exportMaxSnippets)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range snippets {
+			// team snippets are kept
+			if s.TeamID == 0 {
+				app.emitSnippetEvent(models.EventSnippetDeleted, s)
+			}
+		}
+	}
+
+	deliveries, err := app.webhooks.PendingForUser(id)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		app.deliverWebhook(d)
+	}
+
+	return app.users.Delete(id)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// number of characters of the snippet content included in feed entries
+const feedExcerptLength = 280
+
+// metadata shared by the atom and rss representation of a feed
+type feed struct {
+	Title    string
+	Subtitle string
+	SelfURL  string // absolute url of the feed document itself
+	HTMLURL  string // absolute url of the page the feed mirrors
+	Snippets []*models.Snippet
+}
+
+// https://www.rfc-editor.org/rfc/rfc4287
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Author    atomAuthor  `xml:"author"`
+	Link      atomLink    `xml:"link"`
+	Summary   atomContent `xml:"summary"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// https://www.rssboard.org/rss-specification
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Creator     string  `xml:"dc:creator,omitempty"` // rss <author> must be an email address
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// returns the time of the newest snippet in the feed, or now for an empty feed
+func (f *feed) updated() time.Time {
+	if len(f.Snippets) == 0 {
+		return time.Now().UTC()
+	}
+
+	return f.Snippets[0].Created.UTC()
+}
+
+func (app *application) snippetURL(s *models.Snippet) string {
+	return fmt.Sprintf("%s/snippets/%d", app.baseURL, s.ID)
+}
+
+func (app *application) atomFeed(f *feed) *atomFeed {
+	af := &atomFeed{
+		ID:       f.SelfURL,
+		Title:    f.Title,
+		Subtitle: f.Subtitle,
+		Updated:  f.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.HTMLURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	for _, s := range f.Snippets {
+		created := s.Created.UTC().Format(time.RFC3339)
+		url := app.snippetURL(s)
+
+		af.Entries = append(af.Entries, atomEntry{
+			ID:        url,
+			Title:     s.Title,
+			Updated:   created,
+			Published: created,
+			Author:    atomAuthor{Name: snippetAuthor(s)},
+			Link:      atomLink{Href: url, Rel: "alternate", Type: "text/html"},
+			Summary:   atomContent{Type: "text", Body: excerpt(s.Content, feedExcerptLength)},
+		})
+	}
+
+	return af
+}
+
+func (app *application) rssFeed(f *feed) *rssFeed {
+	rf := &rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.HTMLURL,
+			Description:   f.Subtitle,
+			LastBuildDate: f.updated().Format(time.RFC1123Z),
+			AtomLink:      atomLink{Href: f.SelfURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+
+	for _, s := range f.Snippets {
+		url := app.snippetURL(s)
+
+		rf.Channel.Items = append(rf.Channel.Items, rssItem{
+			Title:       s.Title,
+			Link:        url,
+			GUID:        rssGUID{IsPermaLink: true, Value: url},
+			PubDate:     s.Created.UTC().Format(time.RFC1123Z),
+			Creator:     snippetAuthor(s),
+			Description: excerpt(s.Content, feedExcerptLength),
+		})
+	}
+
+	return rf
+}
+
+// encodes the feed document with an xml declaration and the given content type
+func (app *application) writeFeed(w http.ResponseWriter, contentType string, doc any) {
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+}
+
+// falls back to a placeholder for snippets without an owner
+func snippetAuthor(s *models.Snippet) string {
+	if s.Author == "" {
+		return "Anonymous"
+	}
+
+	return s.Author
+}
+
+// returns the first n characters of the value, appending an ellipsis if it was cut
+func excerpt(value string, n int) string {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) <= n {
+		return value
+	}
+
+	runes := []rune(value)
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+func TestFeeds(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const token = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "Atom",
+			urlPath:         "/feed.atom",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/atom+xml; charset=utf-8",
+			wantBody:        "<id>https://gosnipit.test/snippets/1</id>",
+		},
+		{
+			name:            "RSS",
+			urlPath:         "/feed.rss",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/rss+xml; charset=utf-8",
+			wantBody:        `<guid isPermaLink="true">https://gosnipit.test/snippets/1</guid>`,
+		},
+		{
+			name:            "User Atom",
+			urlPath:         "/feeds/" + token + ".atom",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/atom+xml; charset=utf-8",
+			wantBody:        "<name>Mocky McMockface</name>",
+		},
+		{
+			name:            "User Atom private snippet",
+			urlPath:         "/feeds/" + token + ".atom",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/atom+xml; charset=utf-8",
+			wantBody:        "<id>https://gosnipit.test/snippets/4</id>",
+		},
+		{
+			name:            "User RSS",
+			urlPath:         "/feeds/" + token + ".rss",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/rss+xml; charset=utf-8",
+			wantBody:        "<dc:creator>Mocky McMockface</dc:creator>",
+		},
+		{
+			name:     "Unknown token",
+			urlPath:  "/feeds/abcdef.atom",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, headers.Get("Content-Type"), tt.wantContentType)
+			assert.StringContains(t, body, tt.wantBody)
+
+			// the document must be well-formed xml
+			var v any
+			err := xml.NewDecoder(strings.NewReader(body)).Decode(&v)
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	assert.Equal(t, excerpt("  short  ", 10), "short")
+	assert.Equal(t, excerpt("ünïcödé text", 6), "ünïcöd…")
+}
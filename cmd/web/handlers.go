@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"html"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"gosnipit.ricci2511.dev/internal/models"
@@ -26,6 +32,7 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 type userSignupForm struct {
 	Name                string `form:"name"`
+	Username            string `form:"username"`
 	Email               string `form:"email"`
 	Password            string `form:"password"`
 	validator.Validator `form:"-"`
@@ -46,13 +53,25 @@ func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// usernames are case insensitive, they're always stored in lowercase
+	form.Username = strings.ToLower(strings.TrimSpace(form.Username))
+
 	// form validation
 	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Username), "username", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Username, validator.UsernameRX), "username", "This field must be 3 to 30 letters, digits, hyphens or underscores")
+	form.CheckField(validator.NotReserved(form.Username), "username", "This username is reserved")
 	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
 	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
 	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
 
+	err = app.checkPasswordPolicy(&form.Validator, "password", form.Password, form.Name, form.Username, form.Email)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
@@ -60,28 +79,115 @@ func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	id, err := app.users.Insert(form.Name, form.Username, form.Email, form.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
+		switch {
+		case errors.Is(err, models.ErrDuplicateEmail):
 			form.AddFieldError("email", "Email address is already in use")
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.html", data)
-		} else {
+		case errors.Is(err, models.ErrDuplicateUsername):
+			form.AddFieldError("username", "Username is already taken")
+		default:
 			app.serverError(w, err)
+			return
 		}
 
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "signup.html", data)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. You can log in now.")
+	app.audit(r, id, models.AuditSignup, "")
+
+	// the account exists at this point, so a failed email only gets logged,
+	// the user can request a new one from the account page
+	err = app.sendVerificationEmail(id, form.Name, form.Email)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Check your inbox to verify your email address, you can log in now.")
 
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
+// verification links are valid for this long
+const verificationTokenTTL = 24 * time.Hour
+
+// creates a verification token and mails the verification link to the user
+func (app *application) sendVerificationEmail(id int, name, email string) error {
+	token, err := app.tokens.New(id, models.ScopeVerification, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return app.mailer.Send(email, "verify_email.tmpl", map[string]any{
+		"Name": name,
+		"URL":  fmt.Sprintf("%s/user/verify/%s", app.baseURL, token),
+	})
+}
+
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	id, err := app.tokens.Consume(models.ScopeVerification, chi.URLParam(r, "token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.sessionManager.Put(r.Context(), "flash", "This verification link is invalid or has expired")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	err = app.users.SetEmailVerified(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// any other links that are still around are useless now
+	err = app.tokens.DeleteAllForUser(models.ScopeVerification, id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Thanks, your email address has been verified!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) accountVerificationResend(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if user.EmailVerified {
+		app.sessionManager.Put(r.Context(), "flash", "Your email address is already verified")
+		http.Redirect(w, r, "/account", http.StatusSeeOther)
+		return
+	}
+
+	err = app.sendVerificationEmail(user.ID, user.Name, user.Email)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("A new verification link has been sent to %s", user.Email))
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	RememberMe          bool   `form:"remember"`
 	validator.Validator `form:"-"`
 }
 
@@ -112,28 +218,138 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	accountKey := accountFailureKey(form.Email)
+
+	if wait := app.loginWait(r, accountKey); wait > 0 {
+		form.AddNonFieldError(throttledLoginMessage(wait))
+		data := app.newTemplateData(r)
+		data.Form = form
+		setRetryAfter(w, wait)
+		app.render(w, http.StatusTooManyRequests, "login.html", data)
+		return
+	}
+
 	id, err := app.users.Authenticate(form.Email, form.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.AddNonFieldError("Invalid email address or password")
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.html", data)
-		} else {
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			app.serverError(w, err)
+			return
+		}
+
+		userID := 0
+		user, err := app.users.GetByEmail(form.Email)
+		if err == nil {
+			userID = user.ID
+		} else if !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		// the email address is kept so guesses at unknown accounts show up too
+		app.audit(r, userID, models.AuditLoginFailed, form.Email)
+
+		if app.loginFailed(r, accountKey) && userID != 0 {
+			err = app.recordLockout(r, userID, accountKey)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+
+		form.AddNonFieldError("Invalid email address or password")
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+		return
+	}
+
+	app.accountFailures.Reset(accountKey)
+
+	// kept in the session until the login completes, which can be after the 2fa step
+	app.sessionManager.Put(r.Context(), "rememberMe", form.RememberMe)
+
+	app.loginUser(w, r, id)
+}
+
+// continues the login of a user whose password or sso identity was accepted,
+// users with 2fa enabled aren't logged in until the second step succeeds
+func (app *application) loginUser(w http.ResponseWriter, r *http.Request, id int) {
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if user.Suspended {
+		app.sessionManager.Put(r.Context(), "flash", "Your account has been suspended")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	twoFactor, err := app.twoFactor.Enabled(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if twoFactor {
+		err = app.sessionManager.RenewToken(r.Context())
+		if err != nil {
 			app.serverError(w, err)
+			return
 		}
 
+		app.sessionManager.Put(r.Context(), "twoFactorUserID", id)
+		app.sessionManager.Put(r.Context(), "twoFactorExpiry", time.Now().Add(twoFactorLoginTTL).Unix())
+
+		http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
 		return
 	}
 
+	app.completeLogin(w, r, id)
+}
+
+// logs the user in once all authentication steps succeeded and redirects them
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, id int) {
 	// on successful login, renew the session token
-	err = app.sessionManager.RenewToken(r.Context())
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// recorded so the user can see and revoke the session from the sessions page
+	token, err := app.sessions.Insert(id, clientIP(r), r.UserAgent())
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.sessionManager.Put(r.Context(), "sessionToken", token)
+
+	app.audit(r, id, models.AuditLogin, "")
+
+	if app.sessionManager.PopBool(r.Context(), "rememberMe") {
+		rememberToken, err := app.rememberTokens.New(id, app.rememberLifetime)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.setRememberCookie(w, rememberToken)
+	}
+
+	// logging in during the grace period keeps the account
+	cancelled, err := app.users.CancelDeletion(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if cancelled {
+		app.sessionManager.Put(r.Context(), "flash", "Welcome back! The deletion of your account has been cancelled.")
+	}
 
 	// redirect to the page the user was trying to access before logging in
 	path := app.sessionManager.PopString(r.Context(), "redirectPath")
@@ -146,34 +362,113 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) userLogout(w http.ResponseWriter, r *http.Request) {
+	err := app.sessions.Delete(app.sessionID(r), app.authenticatedUserID(r))
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.forgetLogin(w, r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, app.authenticatedUserID(r), models.AuditLogout, "")
+
 	// good practice to also renew the session token on logout
-	err := app.sessionManager.RenewToken(r.Context())
+	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
 	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "sessionToken")
 	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully")
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-type accountPasswordUpdateForm struct {
-	CurrentPassword     string `form:"currentPassword"`
+type userPasswordForgotForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+// reset links are valid for this long
+const passwordResetTokenTTL = time.Hour
+
+func (app *application) userPasswordForgotForm(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userPasswordForgotForm{}
+	app.render(w, http.StatusOK, "forgot.html", data)
+}
+
+func (app *application) userPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	var form userPasswordForgotForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "forgot.html", data)
+		return
+	}
+
+	user, err := app.users.GetByEmail(form.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	// the email is sent in the background so the response takes the same time
+	// whether the address belongs to an account or not
+	if user != nil {
+		app.background(func() {
+			token, err := app.tokens.New(user.ID, models.ScopePasswordReset, passwordResetTokenTTL)
+			if err != nil {
+				app.errorLog.Print(err)
+				return
+			}
+
+			err = app.mailer.Send(user.Email, "password_reset.tmpl", map[string]any{
+				"Name": user.Name,
+				"URL":  fmt.Sprintf("%s/user/password/reset/%s", app.baseURL, token),
+			})
+			if err != nil {
+				app.errorLog.Print(err)
+			}
+		})
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "If an account exists for that email address, we've sent it a link to reset your password")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+type userPasswordResetForm struct {
 	NewPassword         string `form:"newPassword"`
 	NewPasswordConfirm  string `form:"newPasswordConfirm"`
 	validator.Validator `form:"-"`
 }
 
-func (app *application) accountPasswordUpdateForm(w http.ResponseWriter, r *http.Request) {
+func (app *application) userPasswordResetForm(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
-	data.Form = accountPasswordUpdateForm{}
-	app.render(w, http.StatusOK, "password.html", data)
+	data.Form = userPasswordResetForm{}
+	data.Token = chi.URLParam(r, "token")
+	app.render(w, http.StatusOK, "reset.html", data)
 }
 
-func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
-	var form accountPasswordUpdateForm
+func (app *application) userPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var form userPasswordResetForm
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
@@ -181,29 +476,32 @@ func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// form validation
-	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
 	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
 	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
 	form.CheckField(validator.NotBlank(form.NewPasswordConfirm), "newPasswordConfirm", "This field cannot be blank")
 	form.CheckField(form.NewPassword == form.NewPasswordConfirm, "newPasswordConfirm", "Passwords do not match")
 
+	// the user is only known once the token is consumed, so their details can't be checked here
+	err = app.checkPasswordPolicy(&form.Validator, "newPassword", form.NewPassword)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// validate before consuming the token, so a typo doesn't burn the link
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "password.html", data)
+		data.Token = chi.URLParam(r, "token")
+		app.render(w, http.StatusUnprocessableEntity, "reset.html", data)
 		return
 	}
 
-	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
-
-	err = app.users.PasswordUpdate(id, form.CurrentPassword, form.NewPassword)
+	id, err := app.tokens.Consume(models.ScopePasswordReset, chi.URLParam(r, "token"))
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.AddFieldError("currentPassword", "Invalid current password")
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "password.html", data)
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.sessionManager.Put(r.Context(), "flash", "This password reset link is invalid or has expired, please request a new one")
+			http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
 		} else {
 			app.serverError(w, err)
 		}
@@ -211,57 +509,62 @@ func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your password has been successfully updated")
+	err = app.users.PasswordReset(id, form.NewPassword)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-	http.Redirect(w, r, "/account", http.StatusSeeOther)
-}
+	// whoever knew the old password is logged out everywhere
+	err = app.sessions.DeleteAllForUser(id, 0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	// get the id from the url param and check if it's valid
-	id, err := strconv.Atoi(chi.URLParam(r, "snippetID"))
-	if err != nil || id < 1 {
-		app.notFound(w)
+	err = app.rememberTokens.DeleteAllForUser(id, "")
+	if err != nil {
+		app.serverError(w, err)
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	err = app.tokens.DeleteAllForUser(models.ScopePasswordReset, id)
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
-		} else {
-			app.serverError(w, err)
-		}
+		app.serverError(w, err)
+		return
+	}
 
+	app.audit(r, id, models.AuditPasswordReset, "")
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
 		return
 	}
 
-	data := app.newTemplateData(r)
-	data.Snippet = snippet
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "sessionToken")
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset, you can log in with your new password now")
 
-	app.render(w, http.StatusOK, "view.html", data)
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
-func (app *application) snippetCreateForm(w http.ResponseWriter, r *http.Request) {
-	// initialize a basic templateData and render the snipet create form
-	data := app.newTemplateData(r)
-	data.Form = snippetCreateForm{
-		Expires: 7,
-	}
-	app.render(w, http.StatusOK, "create.html", data)
+type accountPasswordUpdateForm struct {
+	CurrentPassword     string `form:"currentPassword"`
+	NewPassword         string `form:"newPassword"`
+	NewPasswordConfirm  string `form:"newPasswordConfirm"`
+	validator.Validator `form:"-"`
 }
 
-// add struct tags to the fields to tell the form decoder how to map the form data to the struct
-type snippetCreateForm struct {
-	Title               string     `form:"title"`
-	Content             string     `form:"content"`
-	Expires             int        `form:"expires"`
-	validator.Validator `form:"-"` // tell decoder to ignore this field
+func (app *application) accountPasswordUpdateForm(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountPasswordUpdateForm{}
+	app.render(w, http.StatusOK, "password.html", data)
 }
 
-func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
-	var form snippetCreateForm
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+	var form accountPasswordUpdateForm
 
-	// fill the snippetCreateForm struct with the relevant form data
 	err := app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
@@ -269,54 +572,1152 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// form validation
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be longer than 100 characters")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must be either 1, 7 or 365")
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirm), "newPasswordConfirm", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirm, "newPasswordConfirm", "Passwords do not match")
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.checkPasswordPolicy(&form.Validator, "newPassword", form.NewPassword, user.Name, user.Username, user.Email)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
 	if !form.Valid() {
-		// create new templateData with the populated errors and render the form again
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		app.render(w, http.StatusUnprocessableEntity, "password.html", data)
+		return
+	}
+
+	err = app.users.PasswordUpdate(id, form.CurrentPassword, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("currentPassword", "Invalid current password")
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "password.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// keep the current session, but log out everyone else who might know the old password
+	err = app.sessions.DeleteAllForUser(id, app.sessionID(r))
 	if err != nil {
 		app.serverError(w, err)
+		return
 	}
 
-	// add flash message to session data
-	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+	err = app.rememberTokens.DeleteAllForUser(id, rememberToken(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-	// redirect user to the page of the newly created snippet
-	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", id), http.StatusSeeOther)
+	app.audit(r, id, models.AuditPasswordChange, "")
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been successfully updated, your other sessions have been logged out")
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
 }
 
-func (app *application) about(w http.ResponseWriter, r *http.Request) {
+type accountEmailUpdateForm struct {
+	Email               string `form:"email"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// email change links are valid for this long
+const emailChangeTokenTTL = 24 * time.Hour
+
+func (app *application) accountEmailUpdateForm(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
-	app.render(w, http.StatusOK, "about.html", data)
+	data.Form = accountEmailUpdateForm{}
+	app.render(w, http.StatusOK, "email.html", data)
 }
 
-func (app *application) account(w http.ResponseWriter, r *http.Request) {
-	// since this handler runs after the authenticate middleware, we can be
-	// sure that the authenticatedUserID value will be in the session data
+func (app *application) accountEmailUpdate(w http.ResponseWriter, r *http.Request) {
+	var form accountEmailUpdateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// form validation
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
 	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 
+	if form.Valid() {
+		err = app.users.RequestEmailChange(id, form.Password, form.Email)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrInvalidCredentials):
+				form.AddFieldError("password", "Invalid password")
+			case errors.Is(err, models.ErrDuplicateEmail):
+				form.AddFieldError("email", "Email address is already in use")
+			default:
+				app.serverError(w, err)
+				return
+			}
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "email.html", data)
+		return
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// only the link for the latest requested address keeps working
+	err = app.tokens.DeleteAllForUser(models.ScopeEmailChange, id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	token, err := app.tokens.New(id, models.ScopeEmailChange, emailChangeTokenTTL)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.mailer.Send(form.Email, "email_change.tmpl", map[string]any{
+		"Name": user.Name,
+		"URL":  fmt.Sprintf("%s/user/email/confirm/%s", app.baseURL, token),
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Check the inbox of your new email address to confirm the change")
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+func (app *application) userEmailConfirm(w http.ResponseWriter, r *http.Request) {
+	id, err := app.tokens.Consume(models.ScopeEmailChange, chi.URLParam(r, "token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.sessionManager.Put(r.Context(), "flash", "This confirmation link is invalid or has expired")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	oldEmail, err := app.users.ConfirmEmailChange(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.sessionManager.Put(r.Context(), "flash", "This confirmation link is invalid or has expired")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		case errors.Is(err, models.ErrDuplicateEmail):
+			app.sessionManager.Put(r.Context(), "flash", "This email address is already in use by another account")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		default:
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
 	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, id, models.AuditEmailChange, oldEmail+" to "+user.Email)
+
+	// let the old address know, in case the change wasn't made by the account owner
+	app.background(func() {
+		err := app.mailer.Send(oldEmail, "email_changed.tmpl", map[string]any{
+			"Name":  user.Name,
+			"Email": user.Email,
+		})
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+	})
+
+	app.sessionManager.Put(r.Context(), "flash", "Your email address has been changed")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	app.renderSnippet(w, r, http.StatusOK, shareCreateForm{Access: models.AccessRead}, commentForm{})
+}
+
+// minimal version of the snippet page meant to be embedded in an iframe
+func (app *application) snippetEmbed(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	// embeds are served without sessions, so there's no flash or auth state to load
+	// and only public snippets get this far
+	data := &templateData{
+		BaseURL: app.baseURL,
+		Snippet: snippet,
+	}
+
+	app.render(w, http.StatusOK, "embed.html", data)
+}
+
+// https://oembed.com/#section2.3
+type oEmbedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	CacheAge     int    `json:"cache_age"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// default dimensions of the embed iframe, shrunk to fit maxwidth and maxheight
+const (
+	oEmbedWidth  = 600
+	oEmbedHeight = 400
+)
+
+func (app *application) oEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// json is the only format supported, the spec mandates a 501 for anything else
+	if format := query.Get("format"); format != "" && format != "json" {
+		app.clientError(w, http.StatusNotImplemented)
+		return
+	}
+
+	id, ok := app.snippetIDFromURL(query.Get("url"))
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
-			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			app.notFound(w)
 		} else {
 			app.serverError(w, err)
 		}
+
+		return
+	}
+
+	// oembed requests are anonymous, so this only lets public snippets through
+	access, err := app.snippetAccess(r, snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if access == "" {
+		app.notFound(w)
+		return
+	}
+
+	width := oEmbedWidth
+	if maxWidth, err := strconv.Atoi(query.Get("maxwidth")); err == nil && maxWidth > 0 && maxWidth < width {
+		width = maxWidth
+	}
+
+	height := oEmbedHeight
+	if maxHeight, err := strconv.Atoi(query.Get("maxheight")); err == nil && maxHeight > 0 && maxHeight < height {
+		height = maxHeight
+	}
+
+	embedURL := fmt.Sprintf("%s/embed", app.snippetURL(snippet))
+
+	app.writeJSON(w, http.StatusOK, oEmbedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        snippet.Title,
+		AuthorName:   snippet.Author,
+		ProviderName: "GoSnipIt",
+		ProviderURL:  app.baseURL + "/",
+		CacheAge:     3600,
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" title="%s"></iframe>`,
+			html.EscapeString(embedURL), width, height, html.EscapeString(snippet.Title)),
+		Width:  width,
+		Height: height,
+	})
+}
+
+// extracts the snippet id from an absolute snippet or embed url of this app
+func (app *application) snippetIDFromURL(rawURL string) (int, bool) {
+	path, ok := strings.CutPrefix(rawURL, app.baseURL+"/snippets/")
+	if !ok {
+		return 0, false
+	}
+
+	path = strings.TrimSuffix(path, "/embed")
+
+	id, err := strconv.Atoi(path)
+	if err != nil || id < 1 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (app *application) snippetCreateForm(w http.ResponseWriter, r *http.Request) {
+	app.renderSnippetCreate(w, r, http.StatusOK, snippetCreateForm{
+		Expires: 7,
+	})
+}
+
+// renders the snippet create form, listing the teams the snippet can be created for
+func (app *application) renderSnippetCreate(w http.ResponseWriter, r *http.Request, status int, form snippetCreateForm) {
+	teams, err := app.teams.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
 	}
 
 	data := app.newTemplateData(r)
-	data.User = user
+	data.Teams = teams
+	data.Form = form
+	app.render(w, status, "create.html", data)
+}
 
-	app.render(w, http.StatusOK, "account.html", data)
+// add struct tags to the fields to tell the form decoder how to map the form data to the struct
+type snippetCreateForm struct {
+	Title               string     `form:"title"`
+	Content             string     `form:"content"`
+	Language            string     `form:"language"`
+	Expires             int        `form:"expires"`
+	Team                int        `form:"team"` // 0 for a personal snippet
+	Private             bool       `form:"private"`
+	validator.Validator `form:"-"` // tell decoder to ignore this field
+}
+
+// validation rules for new snippets, shared by the create form and the api
+func checkSnippet(v *validator.Validator, title, content, language string, expires int) {
+	v.CheckField(validator.NotBlank(title), "title", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(title, 100), "title", "This field cannot be longer than 100 characters")
+	v.CheckField(validator.NotBlank(content), "content", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(language, 30), "language", "This field cannot be longer than 30 characters")
+	v.CheckField(validator.Matches(language, validator.LanguageRX), "language", "This field may only contain letters, digits and + # . _ -")
+	v.CheckField(validator.PermittedValue(expires, 1, 7, 365), "expires", "This field must be either 1, 7 or 365")
+}
+
+func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+
+	// fill the snippetCreateForm struct with the relevant form data
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// form validation
+	checkSnippet(&form.Validator, form.Title, form.Content, form.Language, form.Expires)
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	if form.Team != 0 {
+		role, err := app.teams.Role(form.Team, userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		form.CheckField(role != "", "team", "You are not a member of this team")
+	}
+
+	if !form.Valid() {
+		// render the form again with the populated errors
+		app.renderSnippetCreate(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	var id int
+	if form.Team != 0 {
+		id, err = app.snippets.InsertForTeam(userID, form.Team, form.Title, form.Content, form.Language, form.Expires, form.Private)
+	} else {
+		id, err = app.snippets.Insert(userID, form.Title, form.Content, form.Language, form.Expires, form.Private)
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.emitSnippetEvent(models.EventSnippetCreated, snippet)
+
+	// add flash message to session data
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+
+	// redirect user to the page of the newly created snippet
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", id), http.StatusSeeOther)
+}
+
+type snippetEditForm struct {
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) snippetEditForm(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:   snippet.Title,
+		Content: snippet.Content,
+	}
+	app.render(w, http.StatusOK, "edit.html", data)
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	var form snippetEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be longer than 100 characters")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.html", data)
+		return
+	}
+
+	err = app.updateSnippet(snippet, form.Title, form.Content)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	snippet.Title = form.Title
+	snippet.Content = form.Content
+	app.emitSnippetEvent(models.EventSnippetUpdated, snippet)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *application) snippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	err := app.deleteSnippet(snippet)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.emitSnippetEvent(models.EventSnippetDeleted, snippet)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// the most snippets shown on a profile page
+const profileMaxSnippets = 100
+
+// public profile listing the snippets a user created
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.GetByUsername(chi.URLParam(r, "username"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	snippets, err := app.snippets.ByUser(user.ID, profileMaxSnippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Snippets = snippets
+
+	app.render(w, http.StatusOK, "profile.html", data)
+}
+
+func (app *application) about(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	app.render(w, http.StatusOK, "about.html", data)
+}
+
+func (app *application) account(w http.ResponseWriter, r *http.Request) {
+	// since this handler runs after the authenticate middleware, we can be
+	// sure that the authenticatedUserID value will be in the session data
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	feedToken, err := app.users.FeedToken(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	twoFactor, err := app.twoFactor.Enabled(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	shared, err := app.snippets.SharedWith(id, sharedMaxSnippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.FeedURL = fmt.Sprintf("%s/feeds/%s", app.baseURL, feedToken)
+	data.TwoFactorEnabled = twoFactor
+	data.SharedSnippets = shared
+
+	app.render(w, http.StatusOK, "account.html", data)
+}
+
+type webhookCreateForm struct {
+	URL                 string   `form:"url"`
+	Events              []string `form:"events"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	app.renderAccountWebhooks(w, r, http.StatusOK, webhookCreateForm{Events: models.WebhookEvents})
+}
+
+// renders the webhook list along with the create form
+func (app *application) renderAccountWebhooks(w http.ResponseWriter, r *http.Request, status int, form webhookCreateForm) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	webhooks, err := app.webhooks.ForUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhooks = webhooks
+	data.WebhookEvents = models.WebhookEvents
+	data.Form = form
+
+	app.render(w, status, "webhooks.html", data)
+}
+
+func (app *application) accountWebhookCreate(w http.ResponseWriter, r *http.Request) {
+	var form webhookCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.URL), "url", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.URL, 2048), "url", "This field cannot be longer than 2048 characters")
+	form.CheckField(validator.IsURL(form.URL), "url", "This field must be a valid http or https URL")
+	form.CheckField(!webhookHostBlocked(form.URL), "url", "This field must be a public URL")
+	form.CheckField(len(form.Events) > 0, "events", "Select at least one event")
+	form.CheckField(validator.PermittedValues(form.Events, models.WebhookEvents...), "events", "Unknown event selected")
+
+	if !form.Valid() {
+		app.renderAccountWebhooks(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	id, err := app.webhooks.Insert(userID, form.URL, secret, form.Events)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Webhook successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/account/webhooks/%d", id), http.StatusSeeOther)
+}
+
+func (app *application) accountWebhookView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "webhookID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	webhook, err := app.webhooks.Get(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	deliveries, err := app.webhooks.Deliveries(webhook.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhook = webhook
+	data.Deliveries = deliveries
+
+	app.render(w, http.StatusOK, "webhook.html", data)
+}
+
+func (app *application) accountWebhookDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "webhookID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = app.webhooks.Delete(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Webhook successfully deleted")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// feed of the latest public snippets
+func (app *application) latestFeed() (*feed, error) {
+	snippets, err := app.snippets.Latest()
+	if err != nil {
+		return nil, err
+	}
+
+	return &feed{
+		Title:    "GoSnipIt - Latest Snippets",
+		Subtitle: "The latest snippets published on GoSnipIt",
+		HTMLURL:  app.baseURL + "/",
+		Snippets: snippets,
+	}, nil
+}
+
+func (app *application) feedAtom(w http.ResponseWriter, r *http.Request) {
+	f, err := app.latestFeed()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	f.SelfURL = app.baseURL + "/feed.atom"
+	app.writeFeed(w, "application/atom+xml; charset=utf-8", app.atomFeed(f))
+}
+
+func (app *application) feedRSS(w http.ResponseWriter, r *http.Request) {
+	f, err := app.latestFeed()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	f.SelfURL = app.baseURL + "/feed.rss"
+	app.writeFeed(w, "application/rss+xml; charset=utf-8", app.rssFeed(f))
+}
+
+// feed of the snippets created by the user owning the feed token in the url, the token
+// is only known to the user so their private snippets are included
+func (app *application) userFeed(r *http.Request) (*feed, error) {
+	token := chi.URLParam(r, "feedToken")
+
+	user, err := app.users.GetByFeedToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := app.snippets.OwnedBy(user.ID, 50)
+	if err != nil {
+		return nil, err
+	}
+
+	return &feed{
+		Title:    fmt.Sprintf("GoSnipIt - Snippets by %s", user.Name),
+		Subtitle: fmt.Sprintf("Snippets created by %s on GoSnipIt", user.Name),
+		HTMLURL:  app.baseURL + "/",
+		Snippets: snippets,
+	}, nil
+}
+
+func (app *application) userFeedAtom(w http.ResponseWriter, r *http.Request) {
+	f, err := app.userFeed(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	f.SelfURL = fmt.Sprintf("%s/feeds/%s.atom", app.baseURL, chi.URLParam(r, "feedToken"))
+	app.writeFeed(w, "application/atom+xml; charset=utf-8", app.atomFeed(f))
+}
+
+func (app *application) userFeedRSS(w http.ResponseWriter, r *http.Request) {
+	f, err := app.userFeed(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	f.SelfURL = fmt.Sprintf("%s/feeds/%s.rss", app.baseURL, chi.URLParam(r, "feedToken"))
+	app.writeFeed(w, "application/rss+xml; charset=utf-8", app.rssFeed(f))
+}
+
+func (app *application) accountFeedReset(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	_, err := app.users.ResetFeedToken(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your private feed URLs have been reset")
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+type apiTokenCreateForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderAccountTokens(w, r, http.StatusOK, apiTokenCreateForm{})
+}
+
+// renders the api token list along with the create form
+func (app *application) renderAccountTokens(w http.ResponseWriter, r *http.Request, status int, form apiTokenCreateForm) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	tokens, err := app.apiTokens.ForUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.APITokens = tokens
+	// a freshly created token is only ever shown once
+	data.NewAPIToken = app.sessionManager.PopString(r.Context(), "newAPIToken")
+	data.Form = form
+
+	app.render(w, status, "tokens.html", data)
+}
+
+func (app *application) accountTokenCreate(w http.ResponseWriter, r *http.Request) {
+	var form apiTokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be longer than 100 characters")
+
+	if !form.Valid() {
+		app.renderAccountTokens(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	token, err := app.apiTokens.Insert(id, form.Name)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, id, models.AuditTokenCreate, form.Name)
+
+	app.sessionManager.Put(r.Context(), "newAPIToken", token)
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+func (app *application) accountTokenDelete(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.Atoi(chi.URLParam(r, "tokenID"))
+	if err != nil || tokenID < 1 {
+		app.notFound(w)
+		return
+	}
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = app.apiTokens.Delete(tokenID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.audit(r, id, models.AuditTokenRevoke, fmt.Sprintf("token %d", tokenID))
+
+	app.sessionManager.Put(r.Context(), "flash", "API token successfully revoked")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+func (app *application) accountSessions(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	sessions, err := app.sessions.ForUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	lockouts, err := app.loginLockouts.ForUser(id, 10)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Sessions = sessions
+	data.SessionID = app.sessionID(r)
+	data.LoginLockouts = lockouts
+
+	app.render(w, http.StatusOK, "sessions.html", data)
+}
+
+func (app *application) accountSessionDelete(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.Atoi(chi.URLParam(r, "sessionID"))
+	if err != nil || sessionID < 1 {
+		app.notFound(w)
+		return
+	}
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = app.sessions.Delete(sessionID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	// revoking the current session is the same as logging out
+	if sessionID == app.sessionID(r) {
+		err = app.forgetLogin(w, r)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.audit(r, id, models.AuditLogout, "")
+
+		err = app.sessionManager.RenewToken(r.Context())
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+		app.sessionManager.Remove(r.Context(), "sessionToken")
+		app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully")
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// remember tokens aren't tied to a session, so the other devices are forgotten
+	// too, otherwise the revoked one would just log in again
+	err = app.rememberTokens.DeleteAllForUser(id, rememberToken(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, id, models.AuditSessionRevoke, fmt.Sprintf("session %d", sessionID))
+
+	app.sessionManager.Put(r.Context(), "flash", "Session successfully revoked")
+
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+// logs out everywhere except in the current session
+func (app *application) accountSessionDeleteOthers(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := app.sessions.DeleteAllForUser(id, app.sessionID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.rememberTokens.DeleteAllForUser(id, rememberToken(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, id, models.AuditSessionRevoke, "all other sessions")
+
+	app.sessionManager.Put(r.Context(), "flash", "All your other sessions have been logged out")
+
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+// upper bound for the number of snippets in a single export
+const exportMaxSnippets = 10000
+
+func (app *application) accountExport(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	snippets, err := app.snippets.OwnedBy(id, exportMaxSnippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// build the archive in memory first so errors can still result in a 500
+	buf := new(bytes.Buffer)
+
+	err = writeExport(buf, snippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("gosnipit-export-%s.zip", time.Now().UTC().Format("2006-01-02"))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	buf.WriteTo(w)
+}
+
+type accountImportForm struct {
+	Expires             int `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountImportForm(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountImportForm{
+		Expires: 365,
+	}
+	app.render(w, http.StatusOK, "import.html", data)
+}
+
+// uploads larger than this are rejected
+const importMaxUploadBytes = 10 << 20
+
+// the body is capped by the maxBodyBytes middleware before nosurf parses it
+func (app *application) accountImport(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(importMaxUploadBytes)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.clientError(w, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var form accountImportForm
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must be either 1, 7 or 365")
+
+	var items []*importItem
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		form.AddFieldError("file", "Choose a file to import")
+	} else {
+		defer file.Close()
+
+		var upload []byte
+
+		// the extra byte tells a file at the limit from one that's over it
+		upload, err = io.ReadAll(io.LimitReader(file, importMaxUploadBytes+1))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if header.Size > importMaxUploadBytes || len(upload) > importMaxUploadBytes {
+			form.AddFieldError("file", "The file can't be larger than 10 MB")
+		} else {
+			items, err = readImport(upload, form.Expires)
+			if err != nil {
+				form.AddFieldError("file", err.Error())
+			}
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "import.html", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	results := []*importResult{}
+
+	// every item is validated and inserted on its own, so one bad entry doesn't fail the rest
+	for _, item := range items {
+		item.CheckField(item.Expires != 0, "expires", "The snippet has already expired")
+		checkSnippet(&item.Validator, item.Title, item.Content, item.Language, item.Expires)
+
+		result := &importResult{Name: item.Name, Title: item.Title}
+		results = append(results, result)
+
+		if !item.Valid() {
+			result.Errors = append(result.Errors, item.NonFieldErrors...)
+			for field, msg := range item.FieldErrors {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", field, msg))
+			}
+			sort.Strings(result.Errors)
+			continue
+		}
+
+		id, err := app.snippets.Insert(userID, item.Title, item.Content, item.Language, item.Expires, item.Private)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		result.ID = id
+
+		snippet, err := app.snippets.Get(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.emitSnippetEvent(models.EventSnippetCreated, snippet)
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.ImportResults = results
+
+	app.render(w, http.StatusOK, "import.html", data)
 }
 
 func ping(w http.ResponseWriter, r *http.Request) {
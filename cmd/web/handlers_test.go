@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/models/mocks"
 )
 
 func TestPing(t *testing.T) {
@@ -107,16 +114,9 @@ func TestSnippetCreate(t *testing.T) {
 }
 
 func TestUserSignup(t *testing.T) {
-	app := newTestApplication(t)
-	ts := newTestServer(t, app.routes())
-	defer ts.Close()
-
-	// get the body of the /user/signup page and extract the CSRF token from the form
-	_, _, body := ts.get(t, "/user/signup")
-	csrfToken := extractCSRFToken(t, body)
-
 	const (
 		validName     = "Mocky the II"
+		validUsername = "mocky-the-ii"
 		validPassword = "validPass"
 		validEmail    = "mockythesecond@example.com"
 		formTag       = "<form action=\"/user/signup\" method=\"post\" novalidate>"
@@ -126,23 +126,25 @@ func TestUserSignup(t *testing.T) {
 	tests := []struct {
 		name         string
 		userName     string
+		userUsername string
 		userEmail    string
 		userPassword string
-		csrfToken    string
+		csrfToken    string // the one from the form if empty
 		wantCode     int
 		wantFormTag  string
 	}{
 		{
 			name:         "Valid submission",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    validEmail,
 			userPassword: validPassword,
-			csrfToken:    csrfToken,
 			wantCode:     http.StatusSeeOther,
 		},
 		{
 			name:         "Invalid csrf token",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    validEmail,
 			userPassword: validPassword,
 			csrfToken:    "invalidToken",
@@ -151,45 +153,90 @@ func TestUserSignup(t *testing.T) {
 		{
 			name:         "Empty name",
 			userName:     "",
+			userUsername: validUsername,
 			userEmail:    validEmail,
 			userPassword: validPassword,
-			csrfToken:    csrfToken,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
 		},
 		{
 			name:         "Empty email",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    "",
 			userPassword: validPassword,
-			csrfToken:    csrfToken,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
 		},
 		{
 			name:         "Invalid email",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    "mocky@example.",
 			userPassword: validPassword,
-			csrfToken:    csrfToken,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
 		},
 		{
 			name:         "Short password",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    validEmail,
 			userPassword: "short",
-			csrfToken:    csrfToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Common password",
+			userName:     validName,
+			userUsername: validUsername,
+			userEmail:    validEmail,
+			userPassword: "password123",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Password based on email",
+			userName:     validName,
+			userUsername: validUsername,
+			userEmail:    validEmail,
+			userPassword: "mockythesecond99",
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
 		},
 		{
 			name:         "Duplicate email",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    "dupe@example.com",
 			userPassword: validPassword,
-			csrfToken:    csrfToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Invalid username",
+			userName:     validName,
+			userUsername: "mocky the ii",
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Reserved username",
+			userName:     validName,
+			userUsername: "Admin",
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Duplicate username",
+			userName:     validName,
+			userUsername: "dupe",
+			userEmail:    validEmail,
+			userPassword: validPassword,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
 		},
@@ -197,11 +244,25 @@ func TestUserSignup(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			// a server per case, there are more cases than signups the rate limit allows
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			// get the body of the /user/signup page and extract the CSRF token from the form
+			_, _, page := ts.get(t, "/user/signup")
+
+			csrfToken := tt.csrfToken
+			if csrfToken == "" {
+				csrfToken = extractCSRFToken(t, page)
+			}
+
 			form := url.Values{}
 			form.Add("name", tt.userName)
+			form.Add("username", tt.userUsername)
 			form.Add("email", tt.userEmail)
 			form.Add("password", tt.userPassword)
-			form.Add("csrf_token", tt.csrfToken)
+			form.Add("csrf_token", csrfToken)
 
 			code, _, body := ts.postForm(t, "/user/signup", form)
 			assert.Equal(t, code, tt.wantCode)
@@ -213,3 +274,659 @@ func TestUserSignup(t *testing.T) {
 		})
 	}
 }
+
+func TestUserProfile(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Existing user", "/u/mocky", http.StatusOK, "Some mock title"},
+		{"User without snippets", "/u/unverified", http.StatusOK, "nothing to see here yet"},
+		{"Non-existent user", "/u/nobody", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// the email address is never shown on the public profile
+	_, _, body := ts.get(t, "/u/mocky")
+	assert.Equal(t, strings.Contains(body, "mocked@example.com"), false)
+
+	// snippets link to the profile of their author
+	_, _, body = ts.get(t, "/snippets/1")
+	assert.StringContains(t, body, `<a href="/u/mocky">Mocky McMockface</a>`)
+}
+
+func TestSnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippets/1")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Owned snippet", "/snippets/1/delete", http.StatusSeeOther},
+		{"Non-existent ID", "/snippets/2/delete", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSnippetEmbed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Embed", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/snippets/1/embed")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Some mock content...")
+
+		// framing restrictions are lifted for this route only
+		assert.Equal(t, headers.Get("X-Frame-Options"), "")
+		assert.StringContains(t, headers.Get("Content-Security-Policy"), "frame-ancestors *")
+	})
+
+	t.Run("Regular view", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/snippets/1")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("X-Frame-Options"), "deny")
+		assert.StringContains(t, body, "application/json+oembed")
+	})
+
+	t.Run("Non-existent ID", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippets/2/embed")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestOEmbed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantCode   int
+		wantHTML   string
+		wantWidth  int
+		wantHeight int
+	}{
+		{
+			name:       "Snippet URL",
+			query:      "url=" + url.QueryEscape("https://gosnipit.test/snippets/1"),
+			wantCode:   http.StatusOK,
+			wantHTML:   `<iframe src="https://gosnipit.test/snippets/1/embed" width="600" height="400"`,
+			wantWidth:  600,
+			wantHeight: 400,
+		},
+		{
+			name:       "Max dimensions",
+			query:      "url=" + url.QueryEscape("https://gosnipit.test/snippets/1/embed") + "&maxwidth=300&maxheight=1000",
+			wantCode:   http.StatusOK,
+			wantHTML:   `width="300" height="400"`,
+			wantWidth:  300,
+			wantHeight: 400,
+		},
+		{
+			name:     "Non-existent snippet",
+			query:    "url=" + url.QueryEscape("https://gosnipit.test/snippets/2"),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Foreign URL",
+			query:    "url=" + url.QueryEscape("https://example.com/snippets/1"),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "XML format",
+			query:    "url=" + url.QueryEscape("https://gosnipit.test/snippets/1") + "&format=xml",
+			wantCode: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, "/oembed?"+tt.query)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var rs oEmbedResponse
+			err := json.Unmarshal([]byte(body), &rs)
+			assert.NilError(t, err)
+
+			assert.Equal(t, rs.Type, "rich")
+			assert.StringContains(t, rs.HTML, tt.wantHTML)
+			assert.Equal(t, rs.Width, tt.wantWidth)
+			assert.Equal(t, rs.Height, tt.wantHeight)
+		})
+	}
+}
+
+func TestAccountExport(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, headers, body := ts.get(t, "/account/export")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/zip")
+	assert.StringContains(t, headers.Get("Content-Disposition"), "attachment; filename=\"gosnipit-export-")
+
+	items, err := readImport([]byte(body), 365)
+	assert.NilError(t, err)
+	// private snippets are exported too
+	assert.Equal(t, len(items), 3)
+	assert.Equal(t, items[0].Content, "Some mock content...")
+	assert.Equal(t, items[2].Content, "Some private content...")
+}
+
+func TestAccountImport(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/import")
+	csrfToken := extractCSRFToken(t, body)
+
+	// one valid gist file and one with a title that's too long
+	gist := `[{"description":"","files":{"ok.sh":{"filename":"ok.sh","content":"echo ok"}}},
+		{"description":"` + strings.Repeat("x", 101) + `","files":{"long.sh":{"filename":"long.sh","content":"echo long"}}}]`
+
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	mw.WriteField("csrf_token", csrfToken)
+	mw.WriteField("expires", "7")
+	fw, err := mw.CreateFormFile("file", "gists.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(gist))
+	mw.Close()
+
+	rs, err := ts.Client().Post(ts.URL+"/account/import", mw.FormDataContentType(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.StringContains(t, string(b), "Imported as #1")
+	assert.StringContains(t, string(b), "title: This field cannot be longer than 100 characters")
+}
+
+func TestAccountImportTooLarge(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/import")
+	csrfToken := extractCSRFToken(t, body)
+
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	mw.WriteField("csrf_token", csrfToken)
+	mw.WriteField("expires", "7")
+	fw, err := mw.CreateFormFile("file", "gists.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(bytes.Repeat([]byte("x"), importMaxUploadBytes+1))
+	mw.Close()
+
+	rs, err := ts.Client().Post(ts.URL+"/account/import", mw.FormDataContentType(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	// the body is cut off before the csrf check finds the token
+	if rs.StatusCode != http.StatusRequestEntityTooLarge && rs.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %d; want %d or %d", rs.StatusCode, http.StatusRequestEntityTooLarge, http.StatusBadRequest)
+	}
+}
+
+func TestUserVerify(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantFlash string
+	}{
+		{"Valid token", "/user/verify/" + mocks.MockToken, http.StatusSeeOther, "has been verified"},
+		{"Invalid token", "/user/verify/invalid", http.StatusSeeOther, "invalid or has expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), "/")
+
+			_, _, body := ts.get(t, "/")
+			assert.StringContains(t, body, tt.wantFlash)
+		})
+	}
+}
+
+func TestAccountEmailUpdate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/email")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{"Invalid email", "bob@example.", "mocked1234", http.StatusUnprocessableEntity, "This field must be a valid email address"},
+		{"Wrong password", "bob@example.com", "wrong1234", http.StatusUnprocessableEntity, "Invalid password"},
+		{"Duplicate email", "dupe@example.com", "mocked1234", http.StatusUnprocessableEntity, "Email address is already in use"},
+		{"Valid submission", "bob@example.com", "mocked1234", http.StatusSeeOther, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/account/email", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserEmailConfirm(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantFlash string
+	}{
+		{"Valid token", "/user/email/confirm/" + mocks.MockToken, "Your email address has been changed"},
+		{"Invalid token", "/user/email/confirm/invalid", "invalid or has expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), "/")
+
+			_, _, body := ts.get(t, "/")
+			assert.StringContains(t, body, tt.wantFlash)
+		})
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "unverified@example.com")
+
+	code, headers, _ := ts.get(t, "/snippets/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account")
+
+	_, _, body := ts.get(t, "/account")
+	assert.StringContains(t, body, "Please verify your email address")
+	assert.StringContains(t, body, "Resend verification email")
+}
+
+func TestUserPasswordForgot(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/password/forgot")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+	}{
+		// registered and unknown addresses must be indistinguishable
+		{"Registered email", "mocked@example.com", http.StatusSeeOther},
+		{"Unknown email", "nobody@example.com", http.StatusSeeOther},
+		{"Invalid email", "nobody@", http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/user/password/forgot", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusSeeOther {
+				assert.Equal(t, headers.Get("Location"), "/user/login")
+
+				_, _, body := ts.get(t, "/user/login")
+				assert.StringContains(t, body, "If an account exists for that email address")
+			}
+		})
+	}
+}
+
+func TestUserPasswordReset(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/password/reset/"+mocks.MockToken)
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		token        string
+		password     string
+		confirm      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Valid token", mocks.MockToken, "kestrel-vanilla-42", "kestrel-vanilla-42", http.StatusSeeOther, "/user/login"},
+		{"Invalid token", "invalid", "kestrel-vanilla-42", "kestrel-vanilla-42", http.StatusSeeOther, "/user/password/forgot"},
+		{"Passwords don't match", mocks.MockToken, "kestrel-vanilla-42", "otherpassword", http.StatusUnprocessableEntity, ""},
+		{"Short password", mocks.MockToken, "short", "short", http.StatusUnprocessableEntity, ""},
+		{"Weak password", mocks.MockToken, "newpassword", "newpassword", http.StatusUnprocessableEntity, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("newPassword", tt.password)
+			form.Add("newPasswordConfirm", tt.confirm)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/user/password/reset/"+tt.token, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestAccountSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/sessions")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "(this session)")
+	assert.StringContains(t, body, "Firefox/113.0")
+	assert.StringContains(t, body, "198.51.100.7")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Other session", "/account/sessions/2/delete", http.StatusSeeOther, "/account/sessions"},
+		{"Non-existent session", "/account/sessions/3/delete", http.StatusNotFound, ""},
+		{"All other sessions", "/account/sessions/others/delete", http.StatusSeeOther, "/account/sessions"},
+		{"Current session", "/account/sessions/1/delete", http.StatusSeeOther, "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	// revoking the current session logged the user out
+	code, _, _ = ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestUserLoginLockout(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := func(email, password string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", password)
+		form.Add("csrf_token", csrfToken)
+
+		return ts.postForm(t, "/user/login", form)
+	}
+
+	// the test application locks accounts out after 5 failures
+	for i := 0; i < 5; i++ {
+		code, _, _ := login("mocked@example.com", "wrongpassword")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	// even the right password is rejected now, before it's checked
+	code, headers, body := login("mocked@example.com", "mocked1234")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, headers.Get("Retry-After"), "60")
+	assert.StringContains(t, body, "Too many failed login attempts, please try again in 60 seconds")
+
+	// other accounts aren't affected
+	code, _, _ = login("twofactor@example.com", "mocked1234")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestAccountDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/delete")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		password string
+		mode     string
+		wantCode int
+		wantBody string
+	}{
+		{"Invalid mode", "mocked1234", "shred", http.StatusUnprocessableEntity, "This field must equal delete or anonymize"},
+		{"Wrong password", "wrong1234", "delete", http.StatusUnprocessableEntity, "Invalid password"},
+		{"Valid submission", "mocked1234", "anonymize", http.StatusSeeOther, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("mode", tt.mode)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/account/delete", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// scheduling the deletion logged the user out
+	code, _, _ := ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestPurgeAccount(t *testing.T) {
+	app := newTestApplication(t)
+
+	var events []string
+	app.webhookClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		events = append(events, r.Header.Get(webhookEventHeader))
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: r}, nil
+	})}
+
+	err := app.purgeAccount(1)
+	assert.NilError(t, err)
+
+	// the team snippet is kept, the other two are deleted with the account
+	assert.Equal(t, len(events), 2)
+	for _, event := range events {
+		assert.Equal(t, event, models.EventSnippetDeleted)
+	}
+}
+
+func TestAccountLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", "mocked@example.com")
+	form.Add("password", "mocked1234")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/account/password")
+
+	form = url.Values{}
+	form.Add("currentPassword", "wrong1234")
+	form.Add("newPassword", "mocked5678")
+	form.Add("newPasswordConfirm", "mocked5678")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	for i := 0; i < 5; i++ {
+		code, _, _ = ts.postForm(t, "/account/password", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	// password confirmations on the account pages have their own limit per user
+	code, _, _ = ts.postForm(t, "/account/password", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+}
+
+func TestSignupLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+
+	form := url.Values{}
+	form.Add("name", "Mocky the II")
+	form.Add("username", "mocky-the-ii")
+	form.Add("email", "")
+	form.Add("password", "validPass")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	for i := 0; i < 10; i++ {
+		code, _, _ := ts.postForm(t, "/user/signup", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	// signups share the login limit per ip
+	code, _, _ := ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+}
+
+func TestEmailLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "unverified@example.com")
+
+	_, _, body := ts.get(t, "/account")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	for i := 0; i < 3; i++ {
+		code, _, _ := ts.postForm(t, "/account/verification", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	// every resend is an email to the address, which may not even be the user's
+	code, _, _ := ts.postForm(t, "/account/verification", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+}
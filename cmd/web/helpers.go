@@ -2,24 +2,43 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
 )
 
 func (app *application) newTemplateData(r *http.Request) *templateData {
-	return &templateData{
+	data := &templateData{
 		CurrentYear: time.Now().Year(),
+		BaseURL:     app.baseURL,
 		// retrieve and delete flash message from session data if it exists
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
 		CSRFToken:       nosurf.Token(r),
 	}
+
+	if data.IsAuthenticated {
+		data.AuthenticatedUserID = app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		data.IsModerator = models.RoleAtLeast(app.authenticatedUserRole(r), models.RoleModerator)
+		data.IsAdmin = models.RoleAtLeast(app.authenticatedUserRole(r), models.RoleAdmin)
+	}
+
+	if app.sso != nil {
+		data.SSOName = app.sso.name
+	}
+
+	return data
 }
 
 // writes a stack trace to the errorLog, then depending on whether
@@ -72,6 +91,24 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	buf.WriteTo(w)
 }
 
+// encodes data as json and writes it with the given status code
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) {
+	out, err := json.Marshal(data)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(out)
+}
+
+// sends a json error response, used instead of clientError for api routes
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, map[string]string{"error": message})
+}
+
 // helper to decode form data into a struct (target being the struct to decode into)
 func (app *application) decodePostForm(r *http.Request, target any) error {
 	// parses form data into r.PostForm map
@@ -106,3 +143,78 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// returns the id of the user authenticated by session or api token, 0 if there is none
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// looks the user up by email address if the identifier looks like one, by username otherwise
+func (app *application) userByEmailOrUsername(identifier string) (*models.User, error) {
+	if strings.Contains(identifier, "@") {
+		return app.users.GetByEmail(identifier)
+	}
+
+	return app.users.GetByUsername(strings.ToLower(identifier))
+}
+
+// adds a field error explaining why the password policy refuses the password,
+// userInputs are the details of the user the password is for
+func (app *application) checkPasswordPolicy(v *validator.Validator, key, password string, userInputs ...string) error {
+	// there's no point in judging a password that already failed the basic checks
+	if _, exists := v.FieldErrors[key]; exists {
+		return nil
+	}
+
+	reason, err := app.passwordPolicy.Check(password, userInputs...)
+	if err != nil {
+		return err
+	}
+
+	v.CheckField(reason == "", key, reason)
+
+	return nil
+}
+
+// returns the role of the authenticated user, empty if there is none
+func (app *application) authenticatedUserRole(r *http.Request) string {
+	role, ok := r.Context().Value(userRoleContextKey).(string)
+	if !ok {
+		return ""
+	}
+
+	return role
+}
+
+// sets the Retry-After header, rounded up so clients never retry too early
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// returns the id of the current logged in session, 0 for api requests and anonymous users
+func (app *application) sessionID(r *http.Request) int {
+	id, ok := r.Context().Value(sessionIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// runs fn in a new goroutine, recovering and logging any panic so it can't take the server down
+func (app *application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Print(fmt.Errorf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+// how long a share link can be valid for, in hours
+var shareLinkHours = []int{1, 24, 7 * 24, 30 * 24}
+
+// share link listed on the snippet page, signatures aren't stored so the url is signed again
+type shareLinkView struct {
+	*models.ShareLink
+	URL string
+}
+
+// the signed part of a share link, the expiry is in unix seconds
+func shareLinkMessage(id int, expiry int64) string {
+	return fmt.Sprintf("%d.%d", id, expiry)
+}
+
+func (app *application) shareLinkURL(link *models.ShareLink) string {
+	expiry := link.Expiry.Unix()
+	signature := app.linkSigner.Sign(shareLinkMessage(link.ID, expiry))
+
+	return fmt.Sprintf("%s/links/%d?expires=%d&signature=%s", app.baseURL, link.ID, expiry, signature)
+}
+
+// returns the outstanding share links of the snippet along with their urls
+func (app *application) shareLinkViews(snippetID int) ([]shareLinkView, error) {
+	links, err := app.shareLinks.ForSnippet(snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	views := []shareLinkView{}
+	for _, link := range links {
+		views = append(views, shareLinkView{ShareLink: link, URL: app.shareLinkURL(link)})
+	}
+
+	return views, nil
+}
+
+type shareLinkCreateForm struct {
+	Hours int `form:"hours"`
+}
+
+func (app *application) snippetLinkCreate(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	// public snippets don't need a link to be read
+	if !snippet.Private {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var form shareLinkCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !validator.PermittedValue(form.Hours, shareLinkHours...) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// the expiry ends up in the url in whole seconds
+	expiry := time.Now().Add(time.Duration(form.Hours) * time.Hour).Truncate(time.Second)
+
+	_, err = app.shareLinks.Insert(snippet.ID, app.authenticatedUserID(r), expiry)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Share link created")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *application) snippetLinkDelete(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "linkID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.shareLinks.Delete(id, snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Share link revoked")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// shows the snippet of a share link to anyone holding a valid one, whether they're logged in or not
+func (app *application) linkView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "linkID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	query := r.URL.Query()
+
+	expiry, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	// the signature is checked first so forged links never reach the database
+	if !app.linkSigner.Verify(shareLinkMessage(id, expiry), query.Get("signature")) {
+		app.notFound(w)
+		return
+	}
+
+	if time.Now().Unix() >= expiry {
+		app.clientError(w, http.StatusGone)
+		return
+	}
+
+	// revoked links are deleted
+	link, err := app.shareLinks.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	if link.Expiry.Unix() != expiry {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.Get(link.SnippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	// keep private snippets out of search engines even if a link leaks
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.SnippetAccess = models.AccessRead
+	// no comments are attached, the discussion stays between the people the snippet is shared with
+	data.SnippetLines = snippetLines(snippet.Content)
+	data.ShareLink = link
+
+	app.render(w, http.StatusOK, "view.html", data)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models/mocks"
+	"gosnipit.ricci2511.dev/internal/signing"
+)
+
+func TestLinkView(t *testing.T) {
+	signer, err := signing.New(testLinkKey)
+	assert.NilError(t, err)
+
+	link := func(id int, expiry int64) string {
+		return fmt.Sprintf("/links/%d?expires=%d&signature=%s", id, expiry, signer.Sign(shareLinkMessage(id, expiry)))
+	}
+
+	expiry := mocks.MockShareLinkExpiry.Unix()
+	expired := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Valid", link(1, expiry), http.StatusOK},
+		{"Missing signature", fmt.Sprintf("/links/1?expires=%d", expiry), http.StatusNotFound},
+		{"Wrong signature", fmt.Sprintf("/links/1?expires=%d&signature=%s", expiry, signer.Sign("1.0")), http.StatusNotFound},
+		{"Extended expiry", strings.Replace(link(1, expiry), fmt.Sprint(expiry), fmt.Sprint(expiry+3600), 1), http.StatusNotFound},
+		{"Expired", link(1, expired), http.StatusGone},
+		{"Different expiry than stored", link(1, expiry+3600), http.StatusNotFound},
+		{"Revoked", link(2, expiry), http.StatusNotFound},
+		{"Invalid ID", "/links/nope", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, header, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusOK {
+				assert.Equal(t, header.Get("X-Robots-Tag"), "noindex")
+				assert.StringContains(t, body, "Some private content...")
+				assert.StringContains(t, body, "You are viewing this snippet through a share link")
+			}
+		})
+	}
+}
+
+func TestSnippetLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		hours    string
+		wantCode int
+	}{
+		{"Create", "mocked@example.com", "/snippets/4/links", "24", http.StatusSeeOther},
+		{"Invalid duration", "mocked@example.com", "/snippets/4/links", "2", http.StatusBadRequest},
+		{"Public snippet", "mocked@example.com", "/snippets/1/links", "24", http.StatusBadRequest},
+		{"Writer creates", "admin@example.com", "/snippets/4/links", "24", http.StatusForbidden},
+		{"Revoke", "mocked@example.com", "/snippets/4/links/1/delete", "", http.StatusSeeOther},
+		{"Revoke missing link", "mocked@example.com", "/snippets/4/links/2/delete", "", http.StatusNotFound},
+		{"Writer revokes", "admin@example.com", "/snippets/4/links/1/delete", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			_, _, body := ts.get(t, "/snippets/4")
+			form := url.Values{}
+			form.Add("hours", tt.hours)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+
+	// owners see the outstanding links, signed again with the current key
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippets/4")
+	assert.StringContains(t, body, fmt.Sprintf("https://gosnipit.test/links/1?expires=%d&amp;signature=", mocks.MockShareLinkExpiry.Unix()))
+	assert.StringContains(t, body, `<form action="/snippets/4/links/1/delete" method="post">`)
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"gosnipit.ricci2511.dev/internal/mailer"
 	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/ratelimit"
+	"gosnipit.ricci2511.dev/internal/signing"
+	"gosnipit.ricci2511.dev/internal/validator"
 
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
@@ -23,13 +31,41 @@ import (
 // struct to hold the application-wide dependencies
 type application struct {
 	debug          bool
-	errorLog       *log.Logger
-	infoLog        *log.Logger
-	snippets       models.SnippetModelInterface
-	users          models.UserModelInterface
-	templateCache  map[string]*template.Template
-	formDecoder    *form.Decoder
-	sessionManager *scs.SessionManager
+	baseURL        string // public url of the app, used to build absolute links
+	frameAncestors string // csp frame-ancestors sources allowed to embed snippets
+	deletionGrace  time.Duration
+	// how long "remember me" logins last without being used
+	rememberLifetime time.Duration
+	errorLog         *log.Logger
+	infoLog          *log.Logger
+	snippets         models.SnippetModelInterface
+	users            models.UserModelInterface
+	webhooks         models.WebhookModelInterface
+	webhookClient    *http.Client // only connects to public addresses, see webhooks.go
+	apiTokens        models.APITokenModelInterface
+	tokens           models.TokenModelInterface
+	twoFactor        models.TwoFactorModelInterface
+	identities       models.IdentityModelInterface
+	sessions         models.SessionModelInterface
+	loginLockouts    models.LoginLockoutModelInterface
+	rememberTokens   models.RememberTokenModelInterface
+	auditEvents      models.AuditModelInterface
+	teams            models.TeamModelInterface
+	shares           models.ShareModelInterface
+	shareLinks       models.ShareLinkModelInterface
+	linkSigner       *signing.Signer // signs share links, see links.go
+	stars            models.StarModelInterface
+	comments         models.CommentModelInterface
+	stats            models.StatsModelInterface
+	passwordPolicy   *validator.PasswordPolicy
+	// failed logins per account and per ip address, see bruteforce.go
+	accountFailures *ratelimit.FailureTracker
+	ipFailures      *ratelimit.FailureTracker
+	sso             *ssoProvider // nil unless single sign-on is configured
+	mailer          mailer.Mailer
+	templateCache   map[string]*template.Template
+	formDecoder     *form.Decoder
+	sessionManager  *scs.SessionManager
 }
 
 func main() {
@@ -52,6 +88,36 @@ func main() {
 	dsnStr := fmt.Sprintf("%v:%v@/gosnipit?parseTime=true", env["MYSQL_USER"], env["MYSQL_PASSWORD"])
 	dsn := flag.String("dsn", dsnStr, "MySQL database connection string")
 	debug := flag.Bool("debug", false, "Enable debug mode")
+	baseURL := flag.String("base-url", "https://localhost:4000", "Public base URL used in absolute links")
+	frameAncestors := flag.String("frame-ancestors", "*", "Space separated CSP frame-ancestors allowed to embed snippets")
+
+	// mail is written to -mail-log (stdout by default) unless an smtp server is configured,
+	// the smtp password is read from the SMTP_PASSWORD variable in the .env file
+	smtpAddr := flag.String("smtp-addr", "", "SMTP server address (host:port)")
+	smtpUsername := flag.String("smtp-username", "", "SMTP username")
+	mailSender := flag.String("mail-sender", "GoSnipIt <no-reply@gosnipit.local>", "Sender address of outgoing mail")
+	mailLog := flag.String("mail-log", "", "File to write mail to when no SMTP server is configured")
+
+	// brute-force protection, a few failures are free, then every attempt is delayed more
+	// until the account or ip address is locked out for -login-lockout
+	loginMaxFailures := flag.Int("login-max-failures", 10, "Failed logins after which an account is locked out")
+	loginIPMaxFailures := flag.Int("login-ip-max-failures", 100, "Failed logins after which an IP address is locked out")
+	loginLockout := flag.Duration("login-lockout", 15*time.Minute, "How long accounts and IP addresses stay locked out")
+
+	deletionGrace := flag.Duration("deletion-grace", 7*24*time.Hour, "Time before a deleted account is removed, logging in cancels the deletion")
+	rememberLifetime := flag.Duration("remember-lifetime", 30*24*time.Hour, "How long an unused \"remember me\" login lasts")
+
+	// single sign-on is enabled by setting an issuer, the client secret is read from
+	// the OIDC_CLIENT_SECRET variable in the .env file
+	oidcIssuer := flag.String("oidc-issuer", "", "OpenID Connect issuer URL, enables single sign-on")
+	oidcClientID := flag.String("oidc-client-id", "", "OpenID Connect client ID")
+	oidcName := flag.String("oidc-name", "SSO", "Name of the OpenID Connect provider shown on the login page")
+
+	// the pwned passwords list can be downloaded ordered by hash from haveibeenpwned.com
+	breachedPasswords := flag.String("breached-passwords", "", "File of SHA-1 hashes of breached passwords, sorted by hash")
+
+	// gives an existing account the admin role and exits, used to set up the first admin
+	makeAdmin := flag.String("make-admin", "", "Email address of an account to make an admin, the server isn't started")
 
 	flag.Parse()
 
@@ -63,13 +129,81 @@ func main() {
 	// defer closing the db connection pool until the main() function has finished
 	defer db.Close()
 
+	if *makeAdmin != "" {
+		err = grantAdmin(&models.UserModel{DB: db}, *makeAdmin)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+
+		infoLog.Printf("%s is now an admin", *makeAdmin)
+		return
+	}
+
 	templateCache, err := newTemplateCache()
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
+	// totp secrets are encrypted at rest with a key that lives outside the database
+	totpKey, err := hex.DecodeString(env["TOTP_ENCRYPTION_KEY"])
+	if err != nil || len(totpKey) != 32 {
+		errorLog.Fatal("TOTP_ENCRYPTION_KEY in .env must be 32 hex encoded bytes, generate one with `openssl rand -hex 32`")
+	}
+
+	// share links are signed with the first key, the others keep verifying links signed
+	// before the keys were rotated
+	linkKeys, err := signing.ParseKeys(env["SHARE_LINK_KEYS"])
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	linkSigner, err := signing.New(linkKeys...)
+	if err != nil {
+		errorLog.Fatal("SHARE_LINK_KEYS in .env must list one or more keys of 32 hex encoded bytes, generate one with `openssl rand -hex 32`")
+	}
+
 	formDecoder := form.NewDecoder()
 
+	passwordPolicy := &validator.PasswordPolicy{MinEntropy: validator.DefaultMinPasswordEntropy}
+	if *breachedPasswords != "" {
+		passwordPolicy.Breached, err = validator.OpenBreachedPasswords(*breachedPasswords)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		defer passwordPolicy.Breached.Close()
+	}
+
+	var m mailer.Mailer
+	switch {
+	case *smtpAddr != "":
+		m = &mailer.SMTPMailer{
+			Addr:     *smtpAddr,
+			Username: *smtpUsername,
+			Password: env["SMTP_PASSWORD"],
+			Sender:   *mailSender,
+		}
+	case *mailLog != "":
+		f, err := os.OpenFile(*mailLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		defer f.Close()
+
+		m = mailer.NewLogMailer(f, *mailSender)
+	default:
+		m = mailer.NewLogMailer(os.Stdout, *mailSender)
+	}
+
+	var sso *ssoProvider
+	if *oidcIssuer != "" {
+		redirectURL := strings.TrimSuffix(*baseURL, "/") + "/user/login/sso/callback"
+
+		sso, err = newSSOProvider(context.Background(), *oidcName, *oidcIssuer, *oidcClientID, env["OIDC_CLIENT_SECRET"], redirectURL)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
 	// init session manager, use mysql as session store, expires after 12 hours
 	sessionManager := scs.New()
 	sessionManager.Store = mysqlstore.New(db)
@@ -79,16 +213,59 @@ func main() {
 
 	// init new application struct
 	app := &application{
-		debug:          *debug,
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
+		debug:            *debug,
+		baseURL:          strings.TrimSuffix(*baseURL, "/"),
+		frameAncestors:   *frameAncestors,
+		deletionGrace:    *deletionGrace,
+		rememberLifetime: *rememberLifetime,
+		errorLog:         errorLog,
+		infoLog:          infoLog,
+		snippets:         &models.SnippetModel{DB: db},
+		users:            &models.UserModel{DB: db},
+		webhooks:         &models.WebhookModel{DB: db},
+		webhookClient:    newWebhookClient(),
+		apiTokens:        &models.APITokenModel{DB: db},
+		tokens:           &models.TokenModel{DB: db},
+		twoFactor:        &models.TwoFactorModel{DB: db, Key: totpKey},
+		identities:       &models.IdentityModel{DB: db},
+		sessions:         &models.SessionModel{DB: db, Lifetime: sessionManager.Lifetime},
+		loginLockouts:    &models.LoginLockoutModel{DB: db},
+		rememberTokens:   &models.RememberTokenModel{DB: db},
+		auditEvents:      &models.AuditModel{DB: db},
+		teams:            &models.TeamModel{DB: db},
+		shares:           &models.ShareModel{DB: db},
+		shareLinks:       &models.ShareLinkModel{DB: db},
+		linkSigner:       linkSigner,
+		stars:            &models.StarModel{DB: db},
+		comments:         &models.CommentModel{DB: db},
+		stats:            &models.StatsModel{DB: db},
+		passwordPolicy:   passwordPolicy,
+		accountFailures: ratelimit.NewFailureTracker(ratelimit.FailureConfig{
+			FreeAttempts:    3,
+			Delay:           time.Second,
+			MaxDelay:        30 * time.Second,
+			Threshold:       *loginMaxFailures,
+			LockoutDuration: *loginLockout,
+		}),
+		ipFailures: ratelimit.NewFailureTracker(ratelimit.FailureConfig{
+			FreeAttempts:    10,
+			Delay:           time.Second,
+			MaxDelay:        30 * time.Second,
+			Threshold:       *loginIPMaxFailures,
+			LockoutDuration: *loginLockout,
+		}),
+		sso:            sso,
+		mailer:         m,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
 	}
 
+	// deliver queued webhooks and snippet expiry events in the background
+	go app.runWebhookWorker(15 * time.Second)
+	// remove accounts once their deletion grace period is over
+	go app.runAccountDeletionWorker(5 * time.Minute)
+
 	// restrict elliptic curves to X25519 and P256 which have assembly implementations,
 	// therefore they're less cpu intensive than other curves
 	tlsConfig := &tls.Config{
@@ -111,6 +288,19 @@ func main() {
 	errorLog.Fatal(err)
 }
 
+func grantAdmin(users models.UserModelInterface, email string) error {
+	user, err := users.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return fmt.Errorf("no account with the email address %s", email)
+		}
+
+		return err
+	}
+
+	return users.SetRole(user.ID, models.RoleAdmin)
+}
+
 func openDb(dsn string) (*sql.DB, error) {
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
@@ -2,15 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/justinas/nosurf"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/ratelimit"
 )
 
+const contentSecurityPolicy = "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "deny")
@@ -20,6 +28,17 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// lifts the framing restrictions set by secureHeaders so the route can be embedded
+// in an iframe by the configured frame ancestors, must run after secureHeaders
+func (app *application) allowFraming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy+"; frame-ancestors "+app.frameAncestors)
+		w.Header().Del("X-Frame-Options")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
@@ -49,28 +68,112 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		// the session expired or the user logged out, users who asked to be remembered
+		// get a new session here
+		if id == 0 {
+			var err error
+			id, err = app.restoreRememberedLogin(w, r)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+
 		// user is not authenticated, continue with the next handler
 		if id == 0 {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// check if the user exists in the db
-		exists, err := app.users.Exists(id)
-		if err != nil {
+		// the session has to be recorded for the user, sessions that were revoked
+		// from the sessions page or by a password change are logged out here
+		session, err := app.sessions.Get(app.sessionManager.GetString(r.Context(), "sessionToken"))
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
 			app.serverError(w, err)
 			return
 		}
 
-		if exists {
-			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
-			r = r.WithContext(ctx)
+		if session == nil || session.UserID != id {
+			app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+			app.sessionManager.Remove(r.Context(), "sessionToken")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// suspended users are logged out on their next request
+		user, err := app.users.Get(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
 		}
 
+		if user == nil || user.Suspended {
+			app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+			app.sessionManager.Remove(r.Context(), "sessionToken")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// avoid a write on every request, a minute is precise enough for the sessions page
+		ip := clientIP(r)
+		if time.Since(session.LastSeen) > time.Minute || session.IP != ip {
+			err = app.sessions.Touch(session.ID, ip)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+		ctx = context.WithValue(ctx, sessionIDContextKey, session.ID)
+		ctx = context.WithValue(ctx, userRoleContextKey, user.Role)
+		r = r.WithContext(ctx)
+
 		next.ServeHTTP(w, r)
 	})
 }
 
+// authenticates api requests with a personal api token sent as a bearer token,
+// unlike authenticate it rejects requests without valid credentials
+func (app *application) authenticateAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Authorization")
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			app.apiError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		id, err := app.apiTokens.Authenticate(token)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.apiError(w, http.StatusUnauthorized, "invalid token")
+			} else {
+				app.serverError(w, err)
+			}
+
+			return
+		}
+
+		user, err := app.users.Get(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if user.Suspended {
+			app.apiError(w, http.StatusForbidden, "account suspended")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authenticatedUserIDContextKey, id)
+		ctx = context.WithValue(ctx, userRoleContextKey, user.Role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (app *application) requireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !app.isAuthenticated(r) {
@@ -87,6 +190,53 @@ func (app *application) requireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// only lets users with at least the given role through, must run after requireAuth
+func (app *application) requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !models.RoleAtLeast(app.authenticatedUserRole(r), role) {
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// only lets users with a verified email address through, must run after requireAuth
+func (app *application) requireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := app.users.Get(app.authenticatedUserID(r))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if !user.EmailVerified {
+			app.sessionManager.Put(r.Context(), "flash", "Please verify your email address before creating snippets")
+			http.Redirect(w, r, "/account", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// caps the body of requests to the given path at n bytes, it must run before noSurf
+// since nosurf parses the whole form, multipart uploads included, to find the token
+func maxBodyBytes(path string, n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == path {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // csrf protection with a custom cookie that is HttpOnly and Secure with path "/"
 func noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
@@ -98,3 +248,36 @@ func noSurf(next http.Handler) http.Handler {
 
 	return csrfHandler
 }
+
+// rate limits requests with the given limiter, keyed by user ID for authenticated
+// requests and by client IP otherwise, so it must run after the authenticate or
+// authenticateAPI middleware
+func (app *application) rateLimit(limiter ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + clientIP(r)
+			if id := app.authenticatedUserID(r); id != 0 {
+				key = fmt.Sprintf("user:%d", id)
+			}
+
+			allowed, wait := limiter.Allow(key)
+			if !allowed {
+				setRetryAfter(w, wait)
+				app.clientError(w, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// returns the ip of the client without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
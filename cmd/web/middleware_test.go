@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/ratelimit"
 )
 
 func TestSecureHeaders(t *testing.T) {
@@ -58,3 +60,42 @@ func TestSecureHeaders(t *testing.T) {
 
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+
+	limiter := ratelimit.NewMemoryLimiter(ratelimit.Config{
+		Rate:  ratelimit.Per(1, time.Minute),
+		Burst: 1,
+	})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	h := app.rateLimit(limiter)(next)
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	// first request consumes the only token
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// the client port changes between connections, the ip is what's limited
+	r.RemoteAddr = "192.0.2.1:5678"
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "60")
+
+	// a different client is unaffected
+	r.RemoteAddr = "192.0.2.2:1234"
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
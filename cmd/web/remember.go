@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// cookie holding the "remember me" token, it outlives the session cookie
+const rememberCookieName = "remember_token"
+
+func (app *application) setRememberCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     rememberCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(app.rememberLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearRememberCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     rememberCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// returns the remember token sent with the request, empty if there is none
+func rememberToken(r *http.Request) string {
+	cookie, err := r.Cookie(rememberCookieName)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+// logs the user in again with their remember token once the session expired and
+// rotates the token, returns the id of the user or 0 if there's no valid token
+func (app *application) restoreRememberedLogin(w http.ResponseWriter, r *http.Request) (int, error) {
+	token := rememberToken(r)
+	if token == "" {
+		return 0, nil
+	}
+
+	id, newToken, err := app.rememberTokens.Rotate(token, app.rememberLifetime)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidToken):
+			clearRememberCookie(w)
+			return 0, nil
+		case errors.Is(err, models.ErrTokenReused):
+			// someone else has a copy of the token, the model already revoked all remember
+			// tokens of the user, log them out everywhere else too
+			app.audit(r, id, models.AuditRememberReused, "")
+
+			err = app.sessions.DeleteAllForUser(id, 0)
+			if err != nil {
+				return 0, err
+			}
+
+			clearRememberCookie(w)
+			return 0, nil
+		default:
+			return 0, err
+		}
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return 0, err
+	}
+
+	sessionToken, err := app.sessions.Insert(id, clientIP(r), r.UserAgent())
+	if err != nil {
+		return 0, err
+	}
+
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.sessionManager.Put(r.Context(), "sessionToken", sessionToken)
+
+	app.audit(r, id, models.AuditLoginRemembered, "")
+
+	// empty if a concurrent request rotated the token a moment ago and sets the cookie
+	if newToken != "" {
+		app.setRememberCookie(w, newToken)
+	}
+
+	return id, nil
+}
+
+// revokes the remember token sent with the request and removes the cookie
+func (app *application) forgetLogin(w http.ResponseWriter, r *http.Request) error {
+	token := rememberToken(r)
+	if token == "" {
+		return nil
+	}
+
+	err := app.rememberTokens.Delete(token)
+	if err != nil {
+		return err
+	}
+
+	clearRememberCookie(w)
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models/mocks"
+)
+
+// returns the remember cookie set by a response, nil if there is none
+func rememberCookie(headers http.Header) *http.Cookie {
+	for _, cookie := range (&http.Response{Header: headers}).Cookies() {
+		if cookie.Name == rememberCookieName {
+			return cookie
+		}
+	}
+
+	return nil
+}
+
+func TestUserLoginRememberMe(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "mocked@example.com")
+	form.Add("password", "mocked1234")
+	form.Add("remember", "true")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	cookie := rememberCookie(headers)
+	if cookie == nil {
+		t.Fatal("no remember cookie set")
+	}
+
+	assert.Equal(t, cookie.Value, mocks.MockRememberToken)
+	assert.Equal(t, cookie.HttpOnly, true)
+	assert.Equal(t, cookie.MaxAge, 30*24*60*60)
+
+	_, _, body = ts.get(t, "/account")
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// logging out revokes the token and removes the cookie
+	code, headers, _ = ts.postForm(t, "/user/logout", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	cookie = rememberCookie(headers)
+	if cookie == nil {
+		t.Fatal("remember cookie not cleared")
+	}
+
+	assert.Equal(t, cookie.MaxAge, -1)
+}
+
+func TestRememberedLogin(t *testing.T) {
+	tests := []struct {
+		name         string
+		token        string
+		wantCode     int
+		wantLocation string
+		wantCookie   string
+		wantActions  string
+	}{
+		{"Valid token", mocks.MockRememberToken, http.StatusOK, "", mocks.MockRememberToken, "login_remembered"},
+		{"Reused token", mocks.MockStolenRememberToken, http.StatusSeeOther, "/user/login", "", "remember_token_reused"},
+		{"Invalid token", "mockremember-unknown", http.StatusSeeOther, "/user/login", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			// no session cookie, as if the session expired
+			u, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ts.Client().Jar.SetCookies(u, []*http.Cookie{{Name: rememberCookieName, Value: tt.token}})
+
+			code, headers, _ := ts.get(t, "/account")
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			cookie := rememberCookie(headers)
+			if cookie == nil {
+				t.Fatal("remember cookie not set")
+			}
+
+			assert.Equal(t, cookie.Value, tt.wantCookie)
+
+			actions := app.auditEvents.(*mocks.AuditModel).Actions()
+			assert.Equal(t, strings.Join(actions, ","), tt.wantActions)
+		})
+	}
+}
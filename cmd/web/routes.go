@@ -2,9 +2,12 @@ package main
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/ratelimit"
 	"gosnipit.ricci2511.dev/ui"
 )
 
@@ -28,20 +31,89 @@ func (app *application) routes() http.Handler {
 
 	r.Get("/ping", ping)
 
+	// feeds are fetched by feed readers, so they don't use sessions
+	r.Get("/feed.atom", app.feedAtom)
+	r.Get("/feed.rss", app.feedRSS)
+	r.Get("/feeds/{feedToken:[a-f0-9]+}.atom", app.userFeedAtom)
+	r.Get("/feeds/{feedToken:[a-f0-9]+}.rss", app.userFeedRSS)
+
+	// embeds don't need sessions either, and are the only pages that may be framed
+	r.Get("/oembed", app.oEmbed)
+	r.With(app.allowFraming, app.requireSnippetAccess(models.AccessRead)).Get("/snippets/{snippetID}/embed", app.snippetEmbed)
+
+	// per route group rate limits, each group gets its own token buckets
+	// logging in and recovering access, keyed by ip since the user isn't logged in yet
+	loginLimit := app.rateLimit(ratelimit.NewMemoryLimiter(ratelimit.Config{
+		Rate:  ratelimit.Per(10, time.Minute),
+		Burst: 10,
+	}))
+	// account changes confirmed with the password or a two-factor code, keyed by user
+	// so guessing them is slow even for a stolen session
+	accountLimit := app.rateLimit(ratelimit.NewMemoryLimiter(ratelimit.Config{
+		Rate:  ratelimit.Per(10, time.Hour),
+		Burst: 5,
+	}))
+	// emails sent on the user's request, so an account can't be used to flood an inbox
+	emailLimit := app.rateLimit(ratelimit.NewMemoryLimiter(ratelimit.Config{
+		Rate:  ratelimit.Per(5, time.Hour),
+		Burst: 3,
+	}))
+	snippetCreateLimit := app.rateLimit(ratelimit.NewMemoryLimiter(ratelimit.Config{
+		Rate:  ratelimit.Per(30, time.Hour),
+		Burst: 10,
+	}))
+	// a single import can create hundreds of snippets
+	importLimit := app.rateLimit(ratelimit.NewMemoryLimiter(ratelimit.Config{
+		Rate:  ratelimit.Per(5, time.Hour),
+		Burst: 3,
+	}))
+
+	// json api used by the snip command line client, authenticated with api tokens
+	// instead of sessions so it doesn't need csrf protection either
+	r.Route("/api", func(r chi.Router) {
+		r.Use(app.authenticateAPI)
+
+		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			app.apiError(w, http.StatusNotFound, "not found")
+		})
+
+		r.Route("/snippets", func(r chi.Router) {
+			r.Get("/", app.apiSnippetList)
+			r.With(snippetCreateLimit).Post("/", app.apiSnippetCreate)
+			r.With(app.requireAPISnippetAccess(models.AccessRead)).Get("/{snippetID}", app.apiSnippetView)
+			r.With(app.requireAPISnippetAccess(models.AccessOwner)).Delete("/{snippetID}", app.apiSnippetDelete)
+		})
+	})
+
 	r.Group(func(r chi.Router) {
+		r.Use(maxBodyBytes("/account/import", importMaxUploadBytes))
 		r.Use(noSurf)
 		r.Use(app.sessionManager.LoadAndSave)
 		r.Use(app.authenticate)
 
 		r.Get("/", app.home)
 		r.Get("/about", app.about)
+		r.Get("/u/{username}", app.userProfile)
+
+		// signed share links grant read access to a single snippet until they expire
+		r.Get("/links/{linkID}", app.linkView)
 
 		// rest routes for user
 		r.Route("/user", func(r chi.Router) {
 			r.Get("/signup", app.userSignupForm)
-			r.Post("/signup", app.userSignup)
+			r.With(loginLimit).Post("/signup", app.userSignup)
 			r.Get("/login", app.userLoginForm)
-			r.Post("/login", app.userLogin)
+			r.With(loginLimit).Post("/login", app.userLogin)
+			r.Get("/login/2fa", app.userLoginTwoFactorForm)
+			r.With(loginLimit).Post("/login/2fa", app.userLoginTwoFactor)
+			r.Get("/login/sso", app.userLoginSSO)
+			r.With(loginLimit).Get("/login/sso/callback", app.userLoginSSOCallback)
+			r.Get("/verify/{token}", app.userVerify)
+			r.Get("/email/confirm/{token}", app.userEmailConfirm)
+			r.Get("/password/forgot", app.userPasswordForgotForm)
+			r.With(loginLimit).Post("/password/forgot", app.userPasswordForgot)
+			r.Get("/password/reset/{token}", app.userPasswordResetForm)
+			r.With(loginLimit).Post("/password/reset/{token}", app.userPasswordReset)
 
 			r.With(app.requireAuth).Post("/logout", app.userLogout)
 		})
@@ -50,11 +122,61 @@ func (app *application) routes() http.Handler {
 		r.Route("/snippets", func(r chi.Router) {
 			r.Group(func(r chi.Router) {
 				r.Use(app.requireAuth)
+				r.Use(app.requireVerifiedEmail)
 				r.Get("/create", app.snippetCreateForm)
-				r.Post("/", app.snippetCreate)
+				r.With(snippetCreateLimit).Post("/", app.snippetCreate)
 			})
 
-			r.Get("/{snippetID}", app.snippetView)
+			// access to single snippets is checked by requireSnippetAccess, which loads them
+			// for the handlers, private snippets are only visible to the people allowed to see them
+			r.With(app.requireSnippetAccess(models.AccessRead)).Get("/{snippetID}", app.snippetView)
+			r.With(app.requireSnippetAccess(models.AccessRead)).Get("/{snippetID}/raw", app.snippetRaw)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.requireAuth)
+				r.With(app.requireSnippetAccess(models.AccessRead)).Post("/{snippetID}/star", app.snippetStar)
+				r.With(app.requireSnippetAccess(models.AccessRead)).Post("/{snippetID}/comments", app.commentCreate)
+				r.With(app.requireSnippetAccess(models.AccessRead)).Get("/{snippetID}/comments/{commentID}/edit", app.commentEditForm)
+				r.With(app.requireSnippetAccess(models.AccessRead)).Post("/{snippetID}/comments/{commentID}/edit", app.commentEdit)
+				r.With(app.requireSnippetAccess(models.AccessRead)).Post("/{snippetID}/comments/{commentID}/delete", app.commentDelete)
+				r.With(app.requireSnippetAccess(models.AccessWrite)).Get("/{snippetID}/edit", app.snippetEditForm)
+				r.With(app.requireSnippetAccess(models.AccessWrite)).Post("/{snippetID}/edit", app.snippetEdit)
+				r.With(app.requireSnippetAccess(models.AccessOwner)).Post("/{snippetID}/delete", app.snippetDelete)
+				r.With(app.requireSnippetAccess(models.AccessOwner)).Post("/{snippetID}/shares", app.snippetShareCreate)
+				r.With(app.requireSnippetAccess(models.AccessOwner)).Post("/{snippetID}/shares/{userID}/delete", app.snippetShareDelete)
+				r.With(app.requireSnippetAccess(models.AccessOwner)).Post("/{snippetID}/links", app.snippetLinkCreate)
+				r.With(app.requireSnippetAccess(models.AccessOwner)).Post("/{snippetID}/links/{linkID}/delete", app.snippetLinkDelete)
+			})
+		})
+
+		r.Route("/teams", func(r chi.Router) {
+			r.Use(app.requireAuth)
+			r.Get("/", app.teamList)
+			r.Post("/", app.teamCreate)
+			r.Get("/{teamSlug}", app.teamView)
+			r.Post("/{teamSlug}/delete", app.teamDelete)
+			r.Post("/{teamSlug}/invitations", app.teamInvite)
+			r.Post("/{teamSlug}/invitations/{invitationID}/delete", app.teamInvitationDelete)
+			r.Post("/{teamSlug}/members/{userID}/role", app.teamMemberRole)
+			r.Post("/{teamSlug}/members/{userID}/remove", app.teamMemberRemove)
+		})
+
+		// team invitations are accepted by the invited user, who may have to sign up first
+		r.With(app.requireAuth).Get("/invitations/{token}", app.invitationView)
+		r.With(app.requireAuth).Post("/invitations/{token}", app.invitationAccept)
+
+		// moderation, changing roles is reserved for admins
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.requireAuth)
+			r.Use(app.requireRole(models.RoleModerator))
+			r.Get("/", app.adminStats)
+			r.Get("/users", app.adminUsers)
+			r.Post("/users/{userID}/suspend", app.adminUserSuspend)
+			r.Post("/users/{userID}/unsuspend", app.adminUserUnsuspend)
+			r.With(app.requireRole(models.RoleAdmin)).Post("/users/{userID}/role", app.adminUserRole)
+			r.Post("/snippets/{snippetID}/delete", app.adminSnippetDelete)
+			r.With(app.requireRole(models.RoleAdmin)).Get("/audit", app.adminAudit)
+			r.With(app.requireRole(models.RoleAdmin)).Get("/audit/export", app.adminAuditExport)
 		})
 
 		// rest routes for account
@@ -62,7 +184,35 @@ func (app *application) routes() http.Handler {
 			r.Use(app.requireAuth)
 			r.Get("/", app.account)
 			r.Get("/password", app.accountPasswordUpdateForm)
-			r.Post("/password", app.accountPasswordUpdate)
+			r.With(accountLimit).Post("/password", app.accountPasswordUpdate)
+			r.Get("/email", app.accountEmailUpdateForm)
+			r.With(accountLimit).Post("/email", app.accountEmailUpdate)
+			r.Post("/feed/reset", app.accountFeedReset)
+			r.Get("/export", app.accountExport)
+			r.With(emailLimit).Post("/verification", app.accountVerificationResend)
+			r.Get("/2fa", app.accountTwoFactor)
+			r.Get("/2fa/qr.png", app.accountTwoFactorQR)
+			r.Post("/2fa/enable", app.accountTwoFactorEnable)
+			r.With(accountLimit).Post("/2fa/disable", app.accountTwoFactorDisable)
+			r.With(accountLimit).Post("/2fa/recovery-codes", app.accountTwoFactorRecoveryCodes)
+			r.With(app.requireVerifiedEmail).Get("/import", app.accountImportForm)
+			r.With(app.requireVerifiedEmail, snippetCreateLimit, importLimit).Post("/import", app.accountImport)
+			r.Get("/tokens", app.accountTokens)
+			r.Post("/tokens", app.accountTokenCreate)
+			r.Post("/tokens/{tokenID}/delete", app.accountTokenDelete)
+			r.Get("/delete", app.accountDeleteForm)
+			r.With(accountLimit).Post("/delete", app.accountDelete)
+			r.Get("/delete/sso", app.accountDeleteSSO)
+			r.Get("/security", app.accountSecurity)
+			r.Get("/stars", app.accountStars)
+			r.Post("/stars/{snippetID}/delete", app.accountStarDelete)
+			r.Get("/sessions", app.accountSessions)
+			r.Post("/sessions/others/delete", app.accountSessionDeleteOthers)
+			r.Post("/sessions/{sessionID}/delete", app.accountSessionDelete)
+			r.Get("/webhooks", app.accountWebhooks)
+			r.Post("/webhooks", app.accountWebhookCreate)
+			r.Get("/webhooks/{webhookID}", app.accountWebhookView)
+			r.Post("/webhooks/{webhookID}/delete", app.accountWebhookDelete)
 		})
 	})
 
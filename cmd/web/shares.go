@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+// the most snippets listed as shared with the user on the account page
+const sharedMaxSnippets = 100
+
+// returns the access level the authenticated user has to the snippet, empty if they may not see it:
+// everyone can read public snippets, authors own their snippets and team members get the access of
+// their team role to the team's snippets, access granted by the owner can raise any of these levels
+func (app *application) snippetAccess(r *http.Request, snippet *models.Snippet) (string, error) {
+	access := ""
+	if !snippet.Private {
+		access = models.AccessRead
+	}
+
+	userID := app.authenticatedUserID(r)
+	if userID == 0 {
+		return access, nil
+	}
+
+	if snippet.TeamID != 0 {
+		role, err := app.teams.Role(snippet.TeamID, userID)
+		if err != nil {
+			return "", err
+		}
+
+		if teamAccess := teamSnippetAccess(role, snippet.UserID == userID); teamAccess != "" {
+			access = teamAccess
+		}
+	} else if snippet.UserID == userID {
+		access = models.AccessOwner
+	}
+
+	if access == models.AccessOwner {
+		return access, nil
+	}
+
+	granted, err := app.shares.Access(snippet.ID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if access == "" || models.AccessAtLeast(granted, access) {
+		access = granted
+	}
+
+	return access, nil
+}
+
+// maps a team role to the access it grants to the team's snippets, team snippets are managed
+// by the team owners and their authors, authors who left the team lose that control
+func teamSnippetAccess(role string, isAuthor bool) string {
+	switch {
+	case role == "":
+		return ""
+	case role == models.TeamRoleOwner || isAuthor:
+		return models.AccessOwner
+	case role == models.TeamRoleMaintainer:
+		return models.AccessWrite
+	default:
+		return models.AccessRead
+	}
+}
+
+// updates the snippet loaded by requireSnippetAccess, team snippets belong to the team rather than their author
+func (app *application) updateSnippet(snippet *models.Snippet, title, content string) error {
+	if snippet.TeamID != 0 {
+		return app.snippets.UpdateForTeam(snippet.ID, snippet.TeamID, title, content)
+	}
+
+	return app.snippets.Update(snippet.ID, snippet.UserID, title, content)
+}
+
+// deletes the snippet loaded by requireSnippetAccess, see updateSnippet
+func (app *application) deleteSnippet(snippet *models.Snippet) error {
+	if snippet.TeamID != 0 {
+		return app.snippets.DeleteForTeam(snippet.ID, snippet.TeamID)
+	}
+
+	return app.snippets.Delete(snippet.ID, snippet.UserID)
+}
+
+// loads the snippet from the url param into the request context, making sure the authenticated user
+// has at least the required access to it, fail writes the error response for the given status
+func (app *application) loadSnippet(required string, fail func(w http.ResponseWriter, status int)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.Atoi(chi.URLParam(r, "snippetID"))
+			if err != nil || id < 1 {
+				fail(w, http.StatusNotFound)
+				return
+			}
+
+			snippet, err := app.snippets.Get(id)
+			if err != nil {
+				if errors.Is(err, models.ErrNoRecord) {
+					fail(w, http.StatusNotFound)
+				} else {
+					app.serverError(w, err)
+				}
+
+				return
+			}
+
+			access, err := app.snippetAccess(r, snippet)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+
+			// snippets the user can't see are treated as if they didn't exist
+			if access == "" {
+				fail(w, http.StatusNotFound)
+				return
+			}
+
+			if !models.AccessAtLeast(access, required) {
+				fail(w, http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), snippetContextKey, snippet)
+			ctx = context.WithValue(ctx, snippetAccessContextKey, access)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// loadSnippet for pages
+func (app *application) requireSnippetAccess(required string) func(http.Handler) http.Handler {
+	return app.loadSnippet(required, func(w http.ResponseWriter, status int) {
+		if status == http.StatusNotFound {
+			app.notFound(w)
+		} else {
+			app.clientError(w, status)
+		}
+	})
+}
+
+// loadSnippet for the json api, which doesn't reveal the difference between
+// someone else's snippet and a missing one
+func (app *application) requireAPISnippetAccess(required string) func(http.Handler) http.Handler {
+	return app.loadSnippet(required, func(w http.ResponseWriter, status int) {
+		app.apiError(w, http.StatusNotFound, "snippet not found")
+	})
+}
+
+// returns the snippet loaded by loadSnippet
+func (app *application) snippetFromContext(r *http.Request) *models.Snippet {
+	return r.Context().Value(snippetContextKey).(*models.Snippet)
+}
+
+// returns the access level to the snippet loaded by loadSnippet
+func (app *application) snippetAccessFromContext(r *http.Request) string {
+	access, ok := r.Context().Value(snippetAccessContextKey).(string)
+	if !ok {
+		return ""
+	}
+
+	return access
+}
+
+type shareCreateForm struct {
+	User                string `form:"user"` // email address or username
+	Access              string `form:"access"`
+	validator.Validator `form:"-"`
+}
+
+// renders the snippet page with its comments, owners also get the list of users it was shared with
+// and its share links
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, status int, form shareCreateForm, commentForm commentForm) {
+	snippet := app.snippetFromContext(r)
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.SnippetAccess = app.snippetAccessFromContext(r)
+	data.SnippetLines = snippetLines(snippet.Content)
+	data.Form = form
+	data.CommentForm = commentForm
+
+	var err error
+	data.CommentThreads, err = app.attachComments(r, snippet, data.SnippetLines)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if userID := app.authenticatedUserID(r); userID != 0 {
+		starred, err := app.stars.Starred(userID, snippet.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.Starred = starred
+	}
+
+	if data.SnippetAccess == models.AccessOwner {
+		shares, err := app.shares.ForSnippet(snippet.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.Shares = shares
+		data.ShareAccesses = models.ShareAccesses
+
+		data.ShareLinks, err = app.shareLinkViews(snippet.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.ShareLinkHours = shareLinkHours
+	}
+
+	app.render(w, status, "view.html", data)
+}
+
+func (app *application) snippetShareCreate(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	// public snippets are readable by everyone, so the dialog is only offered for private ones
+	if !snippet.Private {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var form shareCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.User = strings.TrimSpace(form.User)
+
+	form.CheckField(validator.NotBlank(form.User), "user", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Access, models.ShareAccesses...), "access", "This field must be read or write")
+
+	var user *models.User
+	if form.Valid() {
+		user, err = app.userByEmailOrUsername(form.User)
+		switch {
+		case err == nil:
+			form.CheckField(user.ID != snippet.UserID, "user", "You already own this snippet")
+		case errors.Is(err, models.ErrNoRecord):
+			form.AddFieldError("user", "No user with this email address or username")
+		default:
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		app.renderSnippet(w, r, http.StatusUnprocessableEntity, form, commentForm{})
+		return
+	}
+
+	err = app.shares.Upsert(snippet.ID, user.ID, form.Access)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Shared with %s", user.Name))
+
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *application) snippetShareDelete(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil || userID < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.shares.Delete(snippet.ID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Access revoked")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// serves the bare content of the snippet
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(snippet.Content))
+}
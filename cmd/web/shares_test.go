@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+func TestSnippetAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{"Anonymous views", "", "/snippets/4", http.StatusNotFound},
+		{"Anonymous views raw", "", "/snippets/4/raw", http.StatusNotFound},
+		{"Anonymous embeds", "", "/snippets/4/embed", http.StatusNotFound},
+		{"Owner views", "mocked@example.com", "/snippets/4", http.StatusOK},
+		{"Owner edits", "mocked@example.com", "/snippets/4/edit", http.StatusOK},
+		{"Reader views", "unverified@example.com", "/snippets/4", http.StatusOK},
+		{"Reader views raw", "unverified@example.com", "/snippets/4/raw", http.StatusOK},
+		{"Reader edits", "unverified@example.com", "/snippets/4/edit", http.StatusForbidden},
+		{"Writer edits", "admin@example.com", "/snippets/4/edit", http.StatusOK},
+		{"Embedded while logged in", "admin@example.com", "/snippets/4/embed", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.loginAs(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusOK {
+				assert.StringContains(t, body, "Some private content...")
+			}
+		})
+	}
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/snippets/1/raw")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, body, "Some mock content...")
+}
+
+func TestSnippetShare(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		user     string
+		access   string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid", "mocked@example.com", "/snippets/4/shares", "admin-mock", "write", http.StatusSeeOther, ""},
+		{"Valid by email", "mocked@example.com", "/snippets/4/shares", "admin@example.com", "read", http.StatusSeeOther, ""},
+		{"Unknown user", "mocked@example.com", "/snippets/4/shares", "nobody", "read", http.StatusUnprocessableEntity, "No user with this email address or username"},
+		{"Self", "mocked@example.com", "/snippets/4/shares", "mocky", "read", http.StatusUnprocessableEntity, "You already own this snippet"},
+		{"Invalid access", "mocked@example.com", "/snippets/4/shares", "admin-mock", "owner", http.StatusUnprocessableEntity, "This field must be read or write"},
+		{"Public snippet", "mocked@example.com", "/snippets/1/shares", "admin-mock", "read", http.StatusBadRequest, ""},
+		{"Writer shares", "admin@example.com", "/snippets/4/shares", "twofactor", "read", http.StatusForbidden, ""},
+		{"Revoke", "mocked@example.com", "/snippets/4/shares/5/delete", "", "", http.StatusSeeOther, ""},
+		{"Revoke missing share", "mocked@example.com", "/snippets/4/shares/3/delete", "", "", http.StatusNotFound, ""},
+		{"Writer revokes", "admin@example.com", "/snippets/4/shares/2/delete", "", "", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			_, _, body := ts.get(t, "/snippets/4")
+			form := url.Values{}
+			form.Add("user", tt.user)
+			form.Add("access", tt.access)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetShareDialog(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippets/4")
+	assert.StringContains(t, body, `<form action="/snippets/4/shares" method="post">`)
+	assert.StringContains(t, body, "admin@example.com")
+	assert.StringContains(t, body, `<form action="/snippets/4/shares/2/delete" method="post">`)
+
+	// only owners see who a snippet was shared with
+	app = newTestApplication(t)
+	ts = newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "unverified@example.com")
+
+	_, _, body = ts.get(t, "/snippets/4")
+	assert.Equal(t, strings.Contains(body, "/snippets/4/shares"), false)
+	assert.Equal(t, strings.Contains(body, "/snippets/4/edit"), false)
+}
+
+func TestSharedWithMe(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "unverified@example.com")
+
+	code, _, body := ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<a href="/snippets/4">Some shared secret</a>`)
+}
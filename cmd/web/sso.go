@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+// an openid connect provider users can log in with instead of a password
+type ssoProvider struct {
+	name     string // shown on the login button, e.g. the company name
+	config   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// fetches the discovery document of the issuer and sets up the authorization code flow,
+// the provider has to allow redirectURL as a redirect uri for the client
+func newSSOProvider(ctx context.Context, name, issuer, clientID, clientSecret, redirectURL string) (*ssoProvider, error) {
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssoProvider{
+		name: name,
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		// checks the signature against the provider's jwks, the issuer, audience and expiry
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+	}, nil
+}
+
+// claims of the id token used to find or create the account
+type ssoClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	// only a suggestion for the username, it isn't unique across providers
+	PreferredUsername string `json:"preferred_username"`
+}
+
+var (
+	errSSOEmailNotVerified   = errors.New("sso: the provider didn't return a verified email address")
+	errSSOAccountNotVerified = errors.New("sso: the account with the same email address was never verified")
+)
+
+// returns a random url safe string, used for the state, nonce and pkce verifier
+func randomURLToken() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (app *application) userLoginSSO(w http.ResponseWriter, r *http.Request) {
+	app.redirectToSSO(w, r, false)
+}
+
+// redirects to the provider, the state, nonce and pkce verifier are kept in the session
+// until the provider redirects back to the callback, with confirm set the callback only
+// confirms the logged in user's identity instead of logging someone in
+func (app *application) redirectToSSO(w http.ResponseWriter, r *http.Request, confirm bool) {
+	if app.sso == nil {
+		app.notFound(w)
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := randomURLToken()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		values[i] = v
+	}
+
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	app.sessionManager.Put(r.Context(), "ssoState", state)
+	app.sessionManager.Put(r.Context(), "ssoNonce", nonce)
+	app.sessionManager.Put(r.Context(), "ssoVerifier", verifier)
+	app.sessionManager.Put(r.Context(), "ssoConfirm", confirm)
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	url := app.sso.config.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+func (app *application) userLoginSSOCallback(w http.ResponseWriter, r *http.Request) {
+	if app.sso == nil {
+		app.notFound(w)
+		return
+	}
+
+	// popped so every login attempt needs a fresh redirect to the provider
+	state := app.sessionManager.PopString(r.Context(), "ssoState")
+	nonce := app.sessionManager.PopString(r.Context(), "ssoNonce")
+	verifier := app.sessionManager.PopString(r.Context(), "ssoVerifier")
+	confirm := app.sessionManager.PopBool(r.Context(), "ssoConfirm")
+
+	query := r.URL.Query()
+
+	if state == "" || query.Get("state") != state {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// e.g. the user declined the consent screen
+	if query.Get("error") != "" {
+		app.ssoFailed(w, r, fmt.Errorf("sso: provider returned %s: %s", query.Get("error"), query.Get("error_description")))
+		return
+	}
+
+	token, err := app.sso.config.Exchange(r.Context(), query.Get("code"), oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		app.ssoFailed(w, r, err)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		app.ssoFailed(w, r, errors.New("sso: token response doesn't contain an id token"))
+		return
+	}
+
+	idToken, err := app.sso.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		app.ssoFailed(w, r, err)
+		return
+	}
+
+	if idToken.Nonce != nonce {
+		app.ssoFailed(w, r, errors.New("sso: id token nonce doesn't match"))
+		return
+	}
+
+	if confirm {
+		app.confirmSSO(w, r, idToken.Issuer, idToken.Subject)
+		return
+	}
+
+	var claims ssoClaims
+
+	err = idToken.Claims(&claims)
+	if err != nil {
+		app.ssoFailed(w, r, err)
+		return
+	}
+
+	id, err := app.ssoUser(r, idToken.Issuer, idToken.Subject, claims)
+	if err != nil {
+		if errors.Is(err, errSSOEmailNotVerified) {
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Your %s account doesn't have a verified email address", app.sso.name))
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else if errors.Is(err, errSSOAccountNotVerified) {
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("An account with your email address already exists, log in with its password or reset it, then log in with %s again", app.sso.name))
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.loginUser(w, r, id)
+}
+
+// how long a confirmation with the provider stands in for the password
+const ssoConfirmTTL = 10 * time.Minute
+
+// records that the logged in user just proved their identity with the provider to delete
+// their account, the identity has to be linked to it already, a confirmation never links one
+func (app *application) confirmSSO(w http.ResponseWriter, r *http.Request, issuer, subject string) {
+	userID := app.authenticatedUserID(r)
+
+	id, err := app.identities.Get(issuer, subject)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	if userID == 0 || id != userID {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Your %s account isn't linked to this account", app.sso.name))
+		http.Redirect(w, r, "/account/delete", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "ssoConfirmedAt", time.Now().Unix())
+	http.Redirect(w, r, "/account/delete", http.StatusSeeOther)
+}
+
+// reports whether the logged in user confirmed their identity with the provider recently
+func (app *application) ssoConfirmed(r *http.Request) bool {
+	confirmedAt := app.sessionManager.GetInt64(r.Context(), "ssoConfirmedAt")
+	return confirmedAt != 0 && time.Since(time.Unix(confirmedAt, 0)) < ssoConfirmTTL
+}
+
+// logs a failed sso login and sends the user back to the login page,
+// these are usually caused by the provider or the user rather than the app
+func (app *application) ssoFailed(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorLog.Print(err)
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Logging in with %s failed, please try again", app.sso.name))
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// returns the user linked to the identity, linking it to the account with the same
+// verified email address or creating a new account the first time the identity is seen
+func (app *application) ssoUser(r *http.Request, issuer, subject string, claims ssoClaims) (int, error) {
+	id, err := app.identities.Get(issuer, subject)
+	if err == nil {
+		return id, nil
+	}
+
+	if !errors.Is(err, models.ErrNoRecord) {
+		return 0, err
+	}
+
+	// only trust the email address for linking if the provider verified it
+	if claims.Email == "" || !claims.EmailVerified {
+		return 0, errSSOEmailNotVerified
+	}
+
+	user, err := app.users.GetByEmail(claims.Email)
+	switch {
+	case err == nil:
+		// anyone can sign up with someone else's address, linking such an account would
+		// let whoever picked its password log in to the account of the address' owner
+		if !user.EmailVerified {
+			return 0, errSSOAccountNotVerified
+		}
+
+		id = user.ID
+	case errors.Is(err, models.ErrNoRecord):
+		name := claims.Name
+		if name == "" {
+			name, _, _ = strings.Cut(claims.Email, "@")
+		}
+
+		id, err = app.insertSSOUser(name, claims)
+		if err != nil {
+			return 0, err
+		}
+
+		app.audit(r, id, models.AuditSignup, app.sso.name)
+	default:
+		return 0, err
+	}
+
+	err = app.identities.Insert(id, issuer, subject)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// the number of suffixed usernames tried before giving up on a new sso account
+const ssoUsernameAttempts = 10
+
+// creates the account for a first time sso login, the username is derived from the
+// claims and gets a number appended while it's already taken
+func (app *application) insertSSOUser(name string, claims ssoClaims) (int, error) {
+	base := ssoUsername(claims)
+
+	for i := 1; i <= ssoUsernameAttempts; i++ {
+		username, err := ssoUsernameCandidate(base, i)
+		if err != nil {
+			return 0, err
+		}
+
+		id, err := app.users.InsertSSO(name, username, claims.Email)
+		if !errors.Is(err, models.ErrDuplicateUsername) {
+			return id, err
+		}
+	}
+
+	return 0, models.ErrDuplicateUsername
+}
+
+// returns the username to try for the given attempt, starting with the base itself,
+// a random one is used when the base is empty or suffixing it doesn't give a valid username
+func ssoUsernameCandidate(base string, attempt int) (string, error) {
+	username := base
+	if attempt > 1 {
+		username = fmt.Sprintf("%s-%d", base, attempt)
+	}
+
+	if base != "" && validator.Matches(username, validator.UsernameRX) && validator.NotReserved(username) {
+		return username, nil
+	}
+
+	b := make([]byte, 4)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "user-" + hex.EncodeToString(b), nil
+}
+
+// turns the preferred username or the local part of the email address into a valid username,
+// short enough to leave room for a suffix, or returns an empty string if there's no usable one
+func ssoUsername(claims ssoClaims) string {
+	candidate := claims.PreferredUsername
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	username := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.ToLower(candidate))
+
+	username = strings.Trim(username, "-_")
+	if len(username) > 26 {
+		username = username[:26]
+	}
+
+	if !validator.Matches(username, validator.UsernameRX) || !validator.NotReserved(username) {
+		return ""
+	}
+
+	return username
+}
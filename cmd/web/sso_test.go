@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models/mocks"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+// minimal in-process openid connect provider, it logs in whoever is configured
+// in the subject, email and emailVerified fields without showing a login page
+type fakeOIDCProvider struct {
+	*httptest.Server
+	key          *rsa.PrivateKey
+	clientID     string
+	clientSecret string
+
+	subject       string
+	email         string
+	emailVerified bool
+
+	mu    sync.Mutex
+	codes map[string]fakeAuthRequest
+}
+
+type fakeAuthRequest struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &fakeOIDCProvider{
+		key:          key,
+		clientID:     "gosnipit",
+		clientSecret: "secret",
+		codes:        map[string]fakeAuthRequest{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *fakeOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *fakeOIDCProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if q.Get("client_id") != p.clientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := base64.RawURLEncoding.EncodeToString([]byte(q.Get("state")))
+
+	p.mu.Lock()
+	p.codes[code] = fakeAuthRequest{
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		redirectURI: q.Get("redirect_uri"),
+	}
+	p.mu.Unlock()
+
+	redirect := q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (p *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != p.clientID || secret != p.clientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	p.mu.Lock()
+	req, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+
+	// the pkce verifier proves the token request comes from whoever started the login
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != req.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != req.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+
+	idToken := p.sign(map[string]any{
+		"iss":            p.URL,
+		"sub":            p.subject,
+		"aud":            p.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          req.nonce,
+		"email":          p.email,
+		"email_verified": p.emailVerified,
+		"name":           "Fake McFakeface",
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "fake-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+func (p *fakeOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "fake",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// returns an RS256 signed jwt with the claims
+func (p *fakeOIDCProvider) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "fake", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// follows the redirects from the app to the provider and back,
+// returns the status code and location of the callback response
+func (ts *testServer) ssoLogin(t *testing.T) (int, string) {
+	return ts.followSSO(t, "/user/login/sso")
+}
+
+// like ssoLogin but starts the flow at urlPath
+func (ts *testServer) followSSO(t *testing.T, urlPath string) (int, string) {
+	location := ts.URL + urlPath
+
+	for i := 0; i < 3; i++ {
+		rs, err := ts.Client().Get(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+
+		if i == 2 {
+			return rs.StatusCode, rs.Header.Get("Location")
+		}
+
+		if rs.StatusCode != http.StatusSeeOther && rs.StatusCode != http.StatusFound {
+			t.Fatalf("expected a redirect from %s, got %d", location, rs.StatusCode)
+		}
+
+		location = rs.Header.Get("Location")
+	}
+
+	return 0, ""
+}
+
+func TestUserLoginSSO(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+
+	tests := []struct {
+		name          string
+		subject       string
+		email         string
+		emailVerified bool
+		wantLocation  string
+		wantFlash     string
+	}{
+		{"Linked identity", mocks.MockIdentitySubject, "someone@example.com", false, "/snippets/create", ""},
+		{"Linked by email", "new-subject", "mocked@example.com", true, "/snippets/create", ""},
+		{"Unverified email", "new-subject", "mocked@example.com", false, "/user/login", "have a verified email address"},
+		{"Unverified account", "new-subject", "unverified@example.com", true, "/user/login", "An account with your email address already exists"},
+		{"Two-factor user", "other-subject", "twofactor@example.com", true, "/user/login/2fa", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			var err error
+			app.sso, err = newSSOProvider(context.Background(), "Acme", provider.URL, provider.clientID, provider.clientSecret, ts.URL+"/user/login/sso/callback")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			provider.subject = tt.subject
+			provider.email = tt.email
+			provider.emailVerified = tt.emailVerified
+
+			code, location := ts.ssoLogin(t)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, location, tt.wantLocation)
+
+			if tt.wantFlash != "" {
+				_, _, body := ts.get(t, "/user/login")
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+		})
+	}
+}
+
+func TestUserLoginSSOInvalidState(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	var err error
+	app.sso, err = newSSOProvider(context.Background(), "Acme", provider.URL, provider.clientID, provider.clientSecret, ts.URL+"/user/login/sso/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a callback the login wasn't started for, e.g. a forged cross-site request
+	code, _, _ := ts.get(t, "/user/login/sso/callback?code=abc&state=forged")
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	// the login button only shows up once sso is configured
+	_, _, body := ts.get(t, "/user/login")
+	assert.StringContains(t, body, `<a href="/user/login/sso">Log in with Acme</a>`)
+}
+
+func TestUserLoginSSODisabled(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/user/login/sso")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSSOUsername(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims ssoClaims
+		want   string
+	}{
+		{"Preferred username", ssoClaims{PreferredUsername: "Dana.Scully", Email: "dana@example.com"}, "dana-scully"},
+		{"Email local part", ssoClaims{Email: "fox_mulder@example.com"}, "fox_mulder"},
+		{"Too long", ssoClaims{Email: "abcdefghijklmnopqrstuvwxyz0123456789@example.com"}, "abcdefghijklmnopqrstuvwxyz"},
+		{"Too short", ssoClaims{Email: "x@example.com"}, ""},
+		{"Reserved", ssoClaims{PreferredUsername: "admin"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ssoUsername(tt.claims), tt.want)
+		})
+	}
+}
+
+func TestSSOUsernameCandidate(t *testing.T) {
+	random := regexp.MustCompile(`^user-[0-9a-f]{8}$`)
+
+	tests := []struct {
+		name    string
+		base    string
+		attempt int
+		want    string // empty if a random username is expected
+	}{
+		{"First attempt", "dana-scully", 1, "dana-scully"},
+		{"Suffixed", "dana-scully", 3, "dana-scully-3"},
+		{"No base", "", 1, ""},
+		{"No base suffixed", "", 2, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, err := ssoUsernameCandidate(tt.base, tt.attempt)
+			assert.NilError(t, err)
+
+			if tt.want == "" {
+				assert.Equal(t, random.MatchString(username), true)
+				assert.Equal(t, validator.Matches(username, validator.UsernameRX) && validator.NotReserved(username), true)
+			} else {
+				assert.Equal(t, username, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountDeleteSSO(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+
+	tests := []struct {
+		name         string
+		subject      string
+		wantFlash    string
+		wantConfirm  bool
+		wantDeletion int
+	}{
+		{"Linked identity", mocks.MockIdentitySubject, "", true, http.StatusSeeOther},
+		{"Other identity", "other-subject", "linked to this account", false, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			var err error
+			app.sso, err = newSSOProvider(context.Background(), "Acme", provider.URL, provider.clientID, provider.clientSecret, ts.URL+"/user/login/sso/callback")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// an account created by an sso login, its password is random
+			provider.subject = mocks.MockIdentitySubject
+			provider.email = "mocked@example.com"
+			provider.emailVerified = true
+
+			code, _ := ts.ssoLogin(t)
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			_, _, body := ts.get(t, "/account/delete")
+			assert.StringContains(t, body, `<a href="/account/delete/sso">Confirm it's you with Acme</a>`)
+
+			provider.subject = tt.subject
+
+			code, location := ts.followSSO(t, "/account/delete/sso")
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, location, "/account/delete")
+
+			_, _, body = ts.get(t, "/account/delete")
+			if tt.wantFlash != "" {
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+			if tt.wantConfirm {
+				assert.StringContains(t, body, "You confirmed it's you with Acme")
+			}
+
+			form := url.Values{}
+			form.Add("mode", "delete")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body = ts.postForm(t, "/account/delete", form)
+			assert.Equal(t, code, tt.wantDeletion)
+
+			if tt.wantDeletion == http.StatusUnprocessableEntity {
+				assert.StringContains(t, body, "This field cannot be blank")
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// the most snippets listed on the stars page
+const starsMaxSnippets = 100
+
+// the form sets the state instead of flipping it, so submitting it twice is harmless
+type snippetStarForm struct {
+	Starred bool `form:"starred"`
+}
+
+func (app *application) snippetStar(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetFromContext(r)
+
+	var form snippetStarForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	if form.Starred {
+		err = app.stars.Star(userID, snippet.ID)
+	} else {
+		err = app.stars.Unstar(userID, snippet.ID)
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// lists the starred snippets the user can still see, expired ones included
+func (app *application) accountStars(w http.ResponseWriter, r *http.Request) {
+	starred, err := app.snippets.StarredBy(app.authenticatedUserID(r), starsMaxSnippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+
+	// a star doesn't keep access to snippets that were made private or unshared since
+	for _, snippet := range starred {
+		access, err := app.snippetAccess(r, snippet)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if access != "" {
+			data.Snippets = append(data.Snippets, snippet)
+		}
+	}
+
+	app.render(w, http.StatusOK, "stars.html", data)
+}
+
+// removes a star from the stars page, which also lists expired snippets that can't be loaded anymore
+func (app *application) accountStarDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "snippetID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.stars.Unstar(app.authenticatedUserID(r), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Star removed")
+
+	http.Redirect(w, r, "/account/stars", http.StatusSeeOther)
+}
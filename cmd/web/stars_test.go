@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+func TestSnippetStar(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		urlPath   string
+		starred   string
+		csrfToken bool
+		wantCode  int
+	}{
+		{"Star", "mocked@example.com", "/snippets/1/star", "true", true, http.StatusSeeOther},
+		{"Unstar", "mocked@example.com", "/snippets/1/star", "false", true, http.StatusSeeOther},
+		{"Star shared snippet", "admin@example.com", "/snippets/4/star", "true", true, http.StatusSeeOther},
+		{"Star hidden snippet", "unverified@example.com", "/snippets/3/star", "true", true, http.StatusNotFound},
+		{"Missing snippet", "mocked@example.com", "/snippets/2/star", "true", true, http.StatusNotFound},
+		{"Missing CSRF token", "mocked@example.com", "/snippets/1/star", "true", false, http.StatusBadRequest},
+		{"Anonymous", "", "/snippets/1/star", "true", true, http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.loginAs(t, tt.email)
+			}
+
+			_, _, body := ts.get(t, "/user/login")
+			form := url.Values{}
+			form.Add("starred", tt.starred)
+			if tt.csrfToken {
+				form.Add("csrf_token", extractCSRFToken(t, body))
+			}
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.email == "" {
+				assert.Equal(t, header.Get("Location"), "/user/login")
+			} else if code == http.StatusSeeOther {
+				assert.Equal(t, header.Get("Location"), strings.TrimSuffix(tt.urlPath, "/star"))
+			}
+		})
+	}
+}
+
+func TestStarCounts(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, "<td>1</td>")
+
+	_, _, body = ts.get(t, "/snippets/1")
+	assert.StringContains(t, body, "<span>1 star</span>")
+	assert.Equal(t, strings.Contains(body, `action="/snippets/1/star"`), false)
+
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/snippets/1")
+	assert.StringContains(t, body, `<input type="hidden" name="starred" value="false">`)
+	assert.StringContains(t, body, "<button>Unstar</button>")
+
+	_, _, body = ts.get(t, "/snippets/4")
+	assert.StringContains(t, body, "<span>0 stars</span>")
+	assert.StringContains(t, body, `<input type="hidden" name="starred" value="true">`)
+}
+
+func TestAccountStars(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/stars")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<a href="/snippets/1">Some mock title</a>`)
+	assert.StringContains(t, body, `Some expired title <span class="error">(expired)</span>`)
+	assert.Equal(t, strings.Contains(body, `href="/snippets/5"`), false)
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// expired snippets can still be unstarred
+	code, header, _ := ts.postForm(t, "/account/stars/5/delete", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/stars")
+}
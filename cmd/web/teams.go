@@ -0,0 +1,449 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+const (
+	// invitation links are valid for this long
+	teamInvitationTTL = 7 * 24 * time.Hour
+	// the most snippets listed on a team page
+	teamMaxSnippets = 100
+)
+
+type teamCreateForm struct {
+	Name                string `form:"name"`
+	Slug                string `form:"slug"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) teamList(w http.ResponseWriter, r *http.Request) {
+	app.renderTeamList(w, r, http.StatusOK, teamCreateForm{})
+}
+
+// renders the teams of the user along with the create form
+func (app *application) renderTeamList(w http.ResponseWriter, r *http.Request, status int, form teamCreateForm) {
+	teams, err := app.teams.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Teams = teams
+	data.Form = form
+
+	app.render(w, status, "teams.html", data)
+}
+
+func (app *application) teamCreate(w http.ResponseWriter, r *http.Request) {
+	var form teamCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.Slug = strings.ToLower(form.Slug)
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be longer than 100 characters")
+	form.CheckField(validator.NotBlank(form.Slug), "slug", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Slug, validator.UsernameRX), "slug", "This field must be 3 to 30 letters, digits, hyphens or underscores")
+
+	if !form.Valid() {
+		app.renderTeamList(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	_, err = app.teams.Insert(form.Name, form.Slug, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateSlug) {
+			form.AddFieldError("slug", "This URL is already taken")
+			app.renderTeamList(w, r, http.StatusUnprocessableEntity, form)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Team successfully created!")
+
+	http.Redirect(w, r, "/teams/"+form.Slug, http.StatusSeeOther)
+}
+
+// returns the team from the url if the authenticated user has at least the required role in it,
+// along with their role, otherwise it writes an error response and returns nil, teams are
+// hidden from users who aren't members
+func (app *application) memberTeam(w http.ResponseWriter, r *http.Request, required string) (*models.Team, string) {
+	team, err := app.teams.GetBySlug(chi.URLParam(r, "teamSlug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return nil, ""
+	}
+
+	role, err := app.teams.Role(team.ID, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return nil, ""
+	}
+
+	if role == "" {
+		app.notFound(w)
+		return nil, ""
+	}
+
+	if !models.TeamRoleAtLeast(role, required) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, ""
+	}
+
+	return team, role
+}
+
+type teamInviteForm struct {
+	Email               string `form:"email"`
+	Role                string `form:"role"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) teamView(w http.ResponseWriter, r *http.Request) {
+	team, role := app.memberTeam(w, r, models.TeamRoleMember)
+	if team == nil {
+		return
+	}
+
+	app.renderTeam(w, r, http.StatusOK, team, role, teamInviteForm{Role: models.TeamRoleMember})
+}
+
+// renders the team page with its snippets, members and, for maintainers, the invitations
+func (app *application) renderTeam(w http.ResponseWriter, r *http.Request, status int, team *models.Team, role string, form teamInviteForm) {
+	snippets, err := app.snippets.ByTeam(team.ID, teamMaxSnippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	members, err := app.teams.Members(team.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Team = team
+	data.TeamRole = role
+	data.TeamRoles = models.TeamRoles
+	data.Snippets = snippets
+	data.TeamMembers = members
+	data.Form = form
+
+	if models.TeamRoleAtLeast(role, models.TeamRoleMaintainer) {
+		data.TeamInvitations, err = app.teams.Invitations(team.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.render(w, status, "team.html", data)
+}
+
+func (app *application) teamInvite(w http.ResponseWriter, r *http.Request) {
+	team, role := app.memberTeam(w, r, models.TeamRoleMaintainer)
+	if team == nil {
+		return
+	}
+
+	var form teamInviteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	// owners are made by promoting members, so an invitation can't hand out more than that
+	form.CheckField(validator.PermittedValue(form.Role, models.TeamRoleMember, models.TeamRoleMaintainer), "role", "This field must be either member or maintainer")
+
+	if !form.Valid() {
+		app.renderTeam(w, r, http.StatusUnprocessableEntity, team, role, form)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	inviter, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	token, err := app.teams.Invite(team.ID, userID, form.Email, form.Role, teamInvitationTTL)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.mailer.Send(form.Email, "team_invitation.tmpl", map[string]any{
+		"Inviter": inviter.Name,
+		"Team":    team.Name,
+		"Role":    form.Role,
+		"URL":     fmt.Sprintf("%s/invitations/%s", app.baseURL, token),
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("An invitation has been sent to %s", form.Email))
+
+	http.Redirect(w, r, "/teams/"+team.Slug, http.StatusSeeOther)
+}
+
+func (app *application) teamInvitationDelete(w http.ResponseWriter, r *http.Request) {
+	team, _ := app.memberTeam(w, r, models.TeamRoleMaintainer)
+	if team == nil {
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "invitationID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.teams.DeleteInvitation(id, team.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Invitation successfully revoked")
+
+	http.Redirect(w, r, "/teams/"+team.Slug, http.StatusSeeOther)
+}
+
+// returns the id of the member in the url and their role, otherwise it writes
+// an error response and returns 0
+func (app *application) teamMember(w http.ResponseWriter, r *http.Request, team *models.Team) (int, string) {
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return 0, ""
+	}
+
+	role, err := app.teams.Role(team.ID, id)
+	if err != nil {
+		app.serverError(w, err)
+		return 0, ""
+	}
+
+	if role == "" {
+		app.notFound(w)
+		return 0, ""
+	}
+
+	return id, role
+}
+
+type teamMemberRoleForm struct {
+	Role string `form:"role"`
+}
+
+func (app *application) teamMemberRole(w http.ResponseWriter, r *http.Request) {
+	team, _ := app.memberTeam(w, r, models.TeamRoleOwner)
+	if team == nil {
+		return
+	}
+
+	id, _ := app.teamMember(w, r, team)
+	if id == 0 {
+		return
+	}
+
+	var form teamMemberRoleForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !validator.PermittedValue(form.Role, models.TeamRoles...) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.teams.SetRole(team.ID, id, form.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrLastOwner):
+			app.sessionManager.Put(r.Context(), "flash", "The team needs at least one other owner first")
+			http.Redirect(w, r, "/teams/"+team.Slug, http.StatusSeeOther)
+		default:
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Role successfully changed")
+
+	http.Redirect(w, r, "/teams/"+team.Slug, http.StatusSeeOther)
+}
+
+// removes a member from the team, everyone can leave a team, maintainers can remove
+// members and owners can remove anyone
+func (app *application) teamMemberRemove(w http.ResponseWriter, r *http.Request) {
+	team, role := app.memberTeam(w, r, models.TeamRoleMember)
+	if team == nil {
+		return
+	}
+
+	id, memberRole := app.teamMember(w, r, team)
+	if id == 0 {
+		return
+	}
+
+	leaving := id == app.authenticatedUserID(r)
+
+	if !leaving && (role == models.TeamRoleMember || (role == models.TeamRoleMaintainer && memberRole != models.TeamRoleMember)) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := app.teams.RemoveMember(team.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrLastOwner):
+			app.sessionManager.Put(r.Context(), "flash", "The team needs at least one other owner first")
+			http.Redirect(w, r, "/teams/"+team.Slug, http.StatusSeeOther)
+		default:
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	if leaving {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You have left %s", team.Name))
+		http.Redirect(w, r, "/teams", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Member successfully removed")
+
+	http.Redirect(w, r, "/teams/"+team.Slug, http.StatusSeeOther)
+}
+
+func (app *application) teamDelete(w http.ResponseWriter, r *http.Request) {
+	team, _ := app.memberTeam(w, r, models.TeamRoleOwner)
+	if team == nil {
+		return
+	}
+
+	err := app.teams.Delete(team.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s and its snippets have been deleted", team.Name))
+
+	http.Redirect(w, r, "/teams", http.StatusSeeOther)
+}
+
+// returns the invitation from the url if it was sent to the email address of the
+// authenticated user, otherwise it writes an error response and returns nil
+func (app *application) userInvitation(w http.ResponseWriter, r *http.Request) *models.TeamInvitation {
+	invitation, err := app.teams.GetInvitation(chi.URLParam(r, "token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.sessionManager.Put(r.Context(), "flash", "This invitation is invalid or has expired")
+			http.Redirect(w, r, "/teams", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return nil
+	}
+
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return nil
+	}
+
+	// the link could have been forwarded, only the invited address may join
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("This invitation was sent to %s, log in with that account to accept it", invitation.Email))
+		http.Redirect(w, r, "/teams", http.StatusSeeOther)
+		return nil
+	}
+
+	return invitation
+}
+
+func (app *application) invitationView(w http.ResponseWriter, r *http.Request) {
+	invitation := app.userInvitation(w, r)
+	if invitation == nil {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.TeamInvitation = invitation
+	data.Token = chi.URLParam(r, "token")
+
+	app.render(w, http.StatusOK, "invitation.html", data)
+}
+
+func (app *application) invitationAccept(w http.ResponseWriter, r *http.Request) {
+	invitation := app.userInvitation(w, r)
+	if invitation == nil {
+		return
+	}
+
+	_, err := app.teams.AcceptInvitation(chi.URLParam(r, "token"), app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.sessionManager.Put(r.Context(), "flash", "This invitation is invalid or has expired")
+			http.Redirect(w, r, "/teams", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Welcome to %s!", invitation.TeamName))
+
+	http.Redirect(w, r, "/teams/"+invitation.TeamSlug, http.StatusSeeOther)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/models/mocks"
+)
+
+func TestTeamSnippetVisibility(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string // empty for anonymous requests
+		wantCode int
+	}{
+		{"Anonymous", "", http.StatusNotFound},
+		{"Non-member", "unverified@example.com", http.StatusNotFound},
+		{"Owner", "mocked@example.com", http.StatusOK},
+		{"Member", "admin@example.com", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.loginAs(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, "/snippets/3")
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusOK {
+				assert.StringContains(t, body, "Some team secret")
+				assert.StringContains(t, body, `<a href="/teams/mock-squad">Mock Squad</a>`)
+				assert.StringContains(t, body, "<span>(private)</span>")
+			}
+		})
+	}
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/snippets/3/embed")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/oembed?url="+url.QueryEscape("https://gosnipit.test/snippets/3"))
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestTeamSnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippets/create")
+	assert.StringContains(t, body, "Mock Squad")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		team         string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Personal snippet", "0", http.StatusSeeOther, "/snippets/1"},
+		{"Team snippet", "1", http.StatusSeeOther, "/snippets/3"},
+		{"Not a member", "2", http.StatusUnprocessableEntity, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Team snippet")
+			form.Add("content", "Shared with the squad")
+			form.Add("expires", "7")
+			form.Add("team", tt.team)
+			form.Add("private", "true")
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/snippets", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantCode == http.StatusUnprocessableEntity {
+				assert.StringContains(t, body, "You are not a member of this team")
+			}
+		})
+	}
+}
+
+func TestTeams(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/teams")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<a href="/teams/mock-squad">Mock Squad</a>`)
+
+	code, _, body = ts.get(t, "/teams/mock-squad")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Some team secret")
+	assert.StringContains(t, body, "admin@example.com")
+	assert.StringContains(t, body, "twofactor@example.com")
+	csrfToken := extractCSRFToken(t, body)
+
+	code, _, _ = ts.get(t, "/teams/unknown")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		form         url.Values
+		wantCode     int
+		wantLocation string
+	}{
+		{"Create team", "/teams", url.Values{"name": {"New Squad"}, "slug": {"New-Squad"}}, http.StatusSeeOther, "/teams/new-squad"},
+		{"Create team with taken slug", "/teams", url.Values{"name": {"Dupe"}, "slug": {"dupe"}}, http.StatusUnprocessableEntity, ""},
+		{"Create team with invalid slug", "/teams", url.Values{"name": {"Squad"}, "slug": {"a b"}}, http.StatusUnprocessableEntity, ""},
+		{"Invite member", "/teams/mock-squad/invitations", url.Values{"email": {"new@example.com"}, "role": {"member"}}, http.StatusSeeOther, "/teams/mock-squad"},
+		{"Invite owner", "/teams/mock-squad/invitations", url.Values{"email": {"new@example.com"}, "role": {"owner"}}, http.StatusUnprocessableEntity, ""},
+		{"Revoke invitation", "/teams/mock-squad/invitations/2/delete", url.Values{}, http.StatusSeeOther, "/teams/mock-squad"},
+		{"Revoke non-existent invitation", "/teams/mock-squad/invitations/9/delete", url.Values{}, http.StatusNotFound, ""},
+		{"Promote member", "/teams/mock-squad/members/5/role", url.Values{"role": {"maintainer"}}, http.StatusSeeOther, "/teams/mock-squad"},
+		{"Invalid role", "/teams/mock-squad/members/5/role", url.Values{"role": {"boss"}}, http.StatusBadRequest, ""},
+		{"Demote last owner", "/teams/mock-squad/members/1/role", url.Values{"role": {"member"}}, http.StatusSeeOther, "/teams/mock-squad"},
+		{"Remove non-member", "/teams/mock-squad/members/2/remove", url.Values{}, http.StatusNotFound, ""},
+		{"Remove member", "/teams/mock-squad/members/5/remove", url.Values{}, http.StatusSeeOther, "/teams/mock-squad"},
+		{"Delete team", "/teams/mock-squad/delete", url.Values{}, http.StatusSeeOther, "/teams"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, tt.form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestTeamSnippetManagement(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{"Team owner edits", "mocked@example.com", "/snippets/6/edit", http.StatusSeeOther},
+		{"Team owner deletes", "mocked@example.com", "/snippets/6/delete", http.StatusSeeOther},
+		{"Member edits", "admin@example.com", "/snippets/6/edit", http.StatusForbidden},
+		{"Member deletes", "admin@example.com", "/snippets/6/delete", http.StatusForbidden},
+		{"Former member edits", "unverified@example.com", "/snippets/6/edit", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			_, _, body := ts.get(t, "/user/login")
+			form := url.Values{}
+			form.Add("title", "Some orphaned team secret")
+			form.Add("content", "Updated")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestTeamSnippetAccess(t *testing.T) {
+	tests := []struct {
+		role     string
+		isAuthor bool
+		want     string
+	}{
+		{"", false, ""},
+		{"", true, ""},
+		{models.TeamRoleMember, false, models.AccessRead},
+		{models.TeamRoleMember, true, models.AccessOwner},
+		{models.TeamRoleMaintainer, false, models.AccessWrite},
+		{models.TeamRoleOwner, false, models.AccessOwner},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, teamSnippetAccess(tt.role, tt.isAuthor), tt.want)
+	}
+}
+
+func TestTeamPermissions(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{"Non-member views team", "unverified@example.com", "/teams/mock-squad", http.StatusNotFound},
+		{"Member invites", "admin@example.com", "/teams/mock-squad/invitations", http.StatusForbidden},
+		{"Member removes owner", "admin@example.com", "/teams/mock-squad/members/1/remove", http.StatusForbidden},
+		{"Member changes role", "admin@example.com", "/teams/mock-squad/members/5/role", http.StatusForbidden},
+		{"Member deletes team", "admin@example.com", "/teams/mock-squad/delete", http.StatusForbidden},
+		{"Member leaves team", "admin@example.com", "/teams/mock-squad/members/5/remove", http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			_, _, body := ts.get(t, "/teams")
+			form := url.Values{}
+			form.Add("email", "new@example.com")
+			form.Add("role", "maintainer")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			var code int
+			if tt.urlPath == "/teams/mock-squad" {
+				code, _, _ = ts.get(t, tt.urlPath)
+			} else {
+				code, _, _ = ts.postForm(t, tt.urlPath, form)
+			}
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestTeamInvitation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// the invitation page asks anonymous users to log in first
+	code, headers, _ := ts.get(t, "/invitations/"+mocks.MockTeamInvitationToken)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.loginAs(t, "unverified@example.com")
+
+	tests := []struct {
+		name         string
+		token        string
+		wantLocation string
+	}{
+		{"Invalid token", "invalid", "/teams"},
+		{"Sent to someone else", mocks.MockOtherTeamInvitationToken, "/teams"},
+		{"Valid token", mocks.MockTeamInvitationToken, "/teams/mock-squad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, "/invitations/"+tt.token)
+			if tt.token != mocks.MockTeamInvitationToken {
+				assert.Equal(t, code, http.StatusSeeOther)
+				assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+				return
+			}
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, "Join Mock Squad")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ = ts.postForm(t, "/invitations/"+tt.token, form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
@@ -12,14 +12,63 @@ import (
 
 // holds any dynamic data that we want to pass to our HTML templates
 type templateData struct {
-	CurrentYear     int
-	User            *models.User
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string // holds flash messages
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	BaseURL             string
+	User                *models.User
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	SnippetAccess       string // access level of the authenticated user to Snippet
+	Starred             bool   // whether the authenticated user starred Snippet
+	SnippetLines        []snippetLine
+	CommentThreads      []*commentThread // threads about the whole snippet, the others are in SnippetLines
+	Comment             *models.Comment
+	CommentForm         any
+	Shares              []*models.Share
+	ShareAccesses       []string
+	SharedSnippets      []*models.Snippet // snippets other users shared with the authenticated user
+	ShareLink           *models.ShareLink // link the snippet is viewed through, nil on the regular snippet page
+	ShareLinks          []shareLinkView
+	ShareLinkHours      []int
+	Webhook             *models.Webhook
+	Webhooks            []*models.Webhook
+	WebhookEvents       []string
+	Deliveries          []*models.WebhookDelivery
+	FeedURL             string // private feed url without the format extension
+	APITokens           []*models.APIToken
+	NewAPIToken         string
+	ImportResults       []*importResult
+	Token               string // single-use token of an emailed link
+	TwoFactorEnabled    bool
+	TOTPSecret          string   // pending secret shown during 2fa enrollment
+	RecoveryCodes       []string // freshly generated recovery codes, only shown once
+	RecoveryCodesLeft   int
+	SSOName             string // name of the single sign-on provider, empty if it's disabled
+	SSOLinked           bool   // whether the authenticated user can confirm their identity with the provider
+	SSOConfirmed        bool   // whether they did so recently, which stands in for their password
+	Sessions            []*models.Session
+	SessionID           int // id of the session the request belongs to
+	LoginLockouts       []*models.LoginLockout
+	Team                *models.Team
+	Teams               []*models.Team
+	TeamRole            string // role of the authenticated user in Team
+	TeamRoles           []string
+	TeamMembers         []*models.TeamMember
+	TeamInvitation      *models.TeamInvitation
+	TeamInvitations     []*models.TeamInvitation
+	AuditEvents         []*models.AuditEvent
+	AuditActions        []string
+	DeletionDate        time.Time // zero when accounts are deleted immediately
+	Stats               *models.Stats
+	Users               []*models.User
+	Roles               []string
+	Query               string // search query of the admin user list
+	Form                any
+	Flash               string // holds flash messages
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	IsModerator         bool // moderators and admins can access the admin area
+	IsAdmin             bool
+	CSRFToken           string
 }
 
 // formats dates in a human-readable format
@@ -31,9 +80,21 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// reports whether the slice contains the value, used to pre-check checkboxes
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 // global variable to hold the functions that we want to make available in our templates
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"contains":  contains,
 }
 
 // initialiazes a map to hold the template set of all pages with the page file name as the key
@@ -66,5 +127,14 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		cache[name] = ts
 	}
 
+	// embedded snippets are rendered inside iframes on other sites, so they
+	// use a standalone layout that also defines "base" instead of the full page layout
+	ts, err := template.New("embed.html").Funcs(functions).ParseFS(ui.Files, "html/embed.html")
+	if err != nil {
+		return nil, err
+	}
+
+	cache["embed.html"] = ts
+
 	return cache, nil
 }
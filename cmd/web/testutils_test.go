@@ -15,9 +15,30 @@ import (
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
+	"gosnipit.ricci2511.dev/internal/mailer"
 	"gosnipit.ricci2511.dev/internal/models/mocks"
+	"gosnipit.ricci2511.dev/internal/ratelimit"
+	"gosnipit.ricci2511.dev/internal/signing"
+	"gosnipit.ricci2511.dev/internal/validator"
 )
 
+// key share links are signed with in tests
+var testLinkKey = bytes.Repeat([]byte{7}, signing.MinKeyLength)
+
+// lets a function stand in for the transport of an http client
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// webhook client that accepts every delivery without touching the network
+func newTestWebhookClient() *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: r}, nil
+	})}
+}
+
 // helper to create a new application struct with mocked dependencies
 // for use in tests
 func newTestApplication(t *testing.T) *application {
@@ -26,6 +47,11 @@ func newTestApplication(t *testing.T) *application {
 		t.Fatal(err)
 	}
 
+	linkSigner, err := signing.New(testLinkKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	formDecoder := form.NewDecoder()
 
 	sessionManager := scs.New()
@@ -33,10 +59,41 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.Cookie.Secure = true
 
 	return &application{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
-		snippets:       &mocks.SnippetModel{},
-		users:          &mocks.UserModel{},
+		errorLog:         log.New(io.Discard, "", 0),
+		infoLog:          log.New(io.Discard, "", 0),
+		baseURL:          "https://gosnipit.test",
+		frameAncestors:   "*",
+		deletionGrace:    7 * 24 * time.Hour,
+		rememberLifetime: 30 * 24 * time.Hour,
+		snippets:         &mocks.SnippetModel{},
+		users:            &mocks.UserModel{},
+		webhooks:         &mocks.WebhookModel{},
+		webhookClient:    newTestWebhookClient(),
+		apiTokens:        &mocks.APITokenModel{},
+		tokens:           &mocks.TokenModel{},
+		twoFactor:        &mocks.TwoFactorModel{},
+		identities:       &mocks.IdentityModel{},
+		sessions:         &mocks.SessionModel{},
+		loginLockouts:    &mocks.LoginLockoutModel{},
+		rememberTokens:   &mocks.RememberTokenModel{},
+		auditEvents:      &mocks.AuditModel{},
+		teams:            &mocks.TeamModel{},
+		shares:           &mocks.ShareModel{},
+		shareLinks:       &mocks.ShareLinkModel{},
+		linkSigner:       linkSigner,
+		stars:            &mocks.StarModel{},
+		comments:         &mocks.CommentModel{},
+		stats:            &mocks.StatsModel{},
+		passwordPolicy:   &validator.PasswordPolicy{MinEntropy: validator.DefaultMinPasswordEntropy},
+		accountFailures: ratelimit.NewFailureTracker(ratelimit.FailureConfig{
+			Threshold:       5,
+			LockoutDuration: time.Minute,
+		}),
+		ipFailures: ratelimit.NewFailureTracker(ratelimit.FailureConfig{
+			Threshold:       50,
+			LockoutDuration: time.Minute,
+		}),
+		mailer:         mailer.NewLogMailer(io.Discard, "GoSnipIt <no-reply@gosnipit.test>"),
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
@@ -119,3 +176,24 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// helper to log in as the mocked user from internal/models/mocks/users.go
+func (ts *testServer) login(t *testing.T) {
+	ts.loginAs(t, "mocked@example.com")
+}
+
+// helper to log in as any of the mocked users, they all share the same password
+func (ts *testServer) loginAs(t *testing.T, email string) {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "mocked1234")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"gosnipit.ricci2511.dev/internal/models"
+	"gosnipit.ricci2511.dev/internal/totp"
+	"gosnipit.ricci2511.dev/internal/validator"
+)
+
+const (
+	// issuer shown next to the account in authenticator apps
+	totpIssuer = "GoSnipIt"
+
+	// time a user has to enter their code after the password was accepted
+	twoFactorLoginTTL = 5 * time.Minute
+)
+
+type twoFactorForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+// checks a totp code, or a recovery code which is used up in the process
+func (app *application) checkTwoFactorCode(userID int, code string) error {
+	code = strings.TrimSpace(code)
+
+	if len(strings.ReplaceAll(code, " ", "")) == totp.Digits {
+		return app.twoFactor.CheckCode(userID, code)
+	}
+
+	return app.twoFactor.UseRecoveryCode(userID, code)
+}
+
+// returns the user that passed the password step of the login, 0 if there is none or it timed out
+func (app *application) pendingTwoFactorUserID(r *http.Request) int {
+	expiry := app.sessionManager.GetInt64(r.Context(), "twoFactorExpiry")
+	if time.Now().Unix() > expiry {
+		return 0
+	}
+
+	return app.sessionManager.GetInt(r.Context(), "twoFactorUserID")
+}
+
+func (app *application) userLoginTwoFactorForm(w http.ResponseWriter, r *http.Request) {
+	if app.pendingTwoFactorUserID(r) == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = twoFactorForm{}
+	app.render(w, http.StatusOK, "login2fa.html", data)
+}
+
+func (app *application) userLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id := app.pendingTwoFactorUserID(r)
+	if id == 0 {
+		app.sessionManager.Put(r.Context(), "flash", "Your login timed out, please try again")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var form twoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	accountKey := twoFactorFailureKey(id)
+
+	if wait := app.loginWait(r, accountKey); wait > 0 {
+		form.AddFieldError("code", throttledLoginMessage(wait))
+		data := app.newTemplateData(r)
+		data.Form = form
+		setRetryAfter(w, wait)
+		app.render(w, http.StatusTooManyRequests, "login2fa.html", data)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if form.Valid() {
+		err = app.checkTwoFactorCode(id, form.Code)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return
+			}
+
+			app.audit(r, id, models.AuditLoginFailed, "invalid two-factor code")
+
+			if app.loginFailed(r, accountKey) {
+				err = app.recordLockout(r, id, accountKey)
+				if err != nil {
+					app.serverError(w, err)
+					return
+				}
+			}
+
+			form.AddFieldError("code", "Invalid authentication code")
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login2fa.html", data)
+		return
+	}
+
+	app.accountFailures.Reset(accountKey)
+	app.sessionManager.Remove(r.Context(), "twoFactorUserID")
+	app.sessionManager.Remove(r.Context(), "twoFactorExpiry")
+
+	app.completeLogin(w, r, id)
+}
+
+func (app *application) accountTwoFactor(w http.ResponseWriter, r *http.Request) {
+	app.renderAccountTwoFactor(w, r, http.StatusOK, twoFactorForm{}, nil)
+}
+
+// renders the 2fa settings, or the enrollment page with a pending secret if 2fa is off
+func (app *application) renderAccountTwoFactor(w http.ResponseWriter, r *http.Request, status int, form twoFactorForm, recoveryCodes []string) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	enabled, err := app.twoFactor.Enabled(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.TwoFactorEnabled = enabled
+	data.RecoveryCodes = recoveryCodes
+
+	if enabled {
+		data.RecoveryCodesLeft, err = app.twoFactor.RecoveryCodesLeft(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	} else {
+		data.TOTPSecret, err = app.pendingTOTPSecret(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.render(w, status, "twofactor.html", data)
+}
+
+// returns the secret waiting to be confirmed, generating one the first time,
+// so reloading the enrollment page doesn't invalidate an already scanned qr code
+func (app *application) pendingTOTPSecret(userID int) (string, error) {
+	secret, err := app.twoFactor.Secret(userID)
+	if err == nil {
+		return secret, nil
+	}
+
+	if !errors.Is(err, models.ErrNoRecord) {
+		return "", err
+	}
+
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	return secret, app.twoFactor.SetSecret(userID, secret)
+}
+
+// serves the otpauth url of the pending secret as a png qr code
+func (app *application) accountTwoFactorQR(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	enabled, err := app.twoFactor.Enabled(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// never hand out the secret again once it's in use
+	if enabled {
+		app.notFound(w)
+		return
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	secret, err := app.pendingTOTPSecret(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	png, err := qrcode.Encode(totp.URL(totpIssuer, user.Email, secret), qrcode.Medium, 256)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+func (app *application) accountTwoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	var form twoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	// the first code proves the authenticator was set up correctly
+	if form.Valid() {
+		err = app.twoFactor.CheckCode(id, form.Code)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return
+			}
+
+			form.AddFieldError("code", "Invalid authentication code, check the time on your device and try again")
+		}
+	}
+
+	if !form.Valid() {
+		app.renderAccountTwoFactor(w, r, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	codes, err := app.twoFactor.Enable(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, id, models.AuditTwoFactorEnable, "")
+
+	// rendered straight away since this is the only time the recovery codes are shown
+	app.renderAccountTwoFactor(w, r, http.StatusOK, twoFactorForm{}, codes)
+}
+
+func (app *application) accountTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	if !app.checkAccountTwoFactorForm(w, r) {
+		return
+	}
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := app.twoFactor.Disable(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, id, models.AuditTwoFactorDisable, "")
+
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication has been turned off")
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+func (app *application) accountTwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if !app.checkAccountTwoFactorForm(w, r) {
+		return
+	}
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	codes, err := app.twoFactor.RegenerateRecoveryCodes(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, id, models.AuditRecoveryCodes, "")
+
+	app.renderAccountTwoFactor(w, r, http.StatusOK, twoFactorForm{}, codes)
+}
+
+// decodes and checks the code that confirms changes to an enabled 2fa setup,
+// renders the settings page with the error and returns false if it's invalid
+func (app *application) checkAccountTwoFactorForm(w http.ResponseWriter, r *http.Request) bool {
+	var form twoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return false
+	}
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if form.Valid() {
+		err = app.checkTwoFactorCode(id, form.Code)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return false
+			}
+
+			form.AddFieldError("code", "Invalid authentication code")
+		}
+	}
+
+	if !form.Valid() {
+		app.renderAccountTwoFactor(w, r, http.StatusUnprocessableEntity, form, nil)
+		return false
+	}
+
+	return true
+}
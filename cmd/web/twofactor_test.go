@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models/mocks"
+)
+
+func TestUserLoginTwoFactor(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "twofactor@example.com")
+	form.Add("password", "mocked1234")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login/2fa")
+
+	// the password alone doesn't log the user in, the login then continues to /account
+	code, headers, _ = ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	tests := []struct {
+		name         string
+		code         string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Empty code", "", http.StatusUnprocessableEntity, ""},
+		{"Wrong code", "654321", http.StatusUnprocessableEntity, ""},
+		{"Valid code", mocks.MockTOTPCode, http.StatusSeeOther, "/account"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("code", tt.code)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/user/login/2fa", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	code, _, _ = ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestUserLoginTwoFactorRecoveryCode(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "twofactor@example.com")
+
+	_, _, body := ts.get(t, "/user/login/2fa")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("code", mocks.MockRecoveryCode)
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/user/login/2fa", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippets/create")
+}
+
+func TestAccountTwoFactorEnable(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/2fa")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "JBSWY3DPEHPK3PXP")
+	csrfToken := extractCSRFToken(t, body)
+
+	code, headers, _ := ts.get(t, "/account/2fa/qr.png")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "image/png")
+
+	form := url.Values{}
+	form.Add("code", "654321")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, "/account/2fa/enable", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	form.Set("code", mocks.MockTOTPCode)
+
+	code, _, body = ts.postForm(t, "/account/2fa/enable", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, mocks.MockRecoveryCode)
+
+	code, _, body = ts.postForm(t, "/account/2fa/recovery-codes", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, mocks.MockRecoveryCode)
+
+	code, _, _ = ts.postForm(t, "/account/2fa/disable", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	actions := app.auditEvents.(*mocks.AuditModel).Actions()
+	assert.Equal(t, strings.Join(actions, ","), "login,two_factor_enable,recovery_codes_regenerate,two_factor_disable")
+}
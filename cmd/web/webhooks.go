@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+const (
+	// header holding the hex encoded HMAC-SHA256 of the request body, prefixed with "sha256="
+	webhookSignatureHeader = "X-GoSnipIt-Signature"
+	webhookEventHeader     = "X-GoSnipIt-Event"
+	webhookDeliveryHeader  = "X-GoSnipIt-Delivery"
+
+	// a delivery is given up on after this many failed attempts
+	webhookMaxAttempts = 8
+	// first retry happens after this delay, doubling with every further attempt
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+
+	// finished deliveries are removed from the log after this long
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+
+	// shown in the delivery log instead of the error, which could tell apart closed ports,
+	// unknown hosts and timeouts
+	webhookRequestFailed = "The request failed before a response was received"
+)
+
+var errWebhookAddressBlocked = errors.New("webhook: refusing to connect to a non-public address")
+
+// ranges that aren't reachable from the internet but aren't covered by the net.IP methods
+var webhookBlockedNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"), // carrier-grade nat
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),  // benchmarking
+	mustParseCIDR("240.0.0.0/4"),    // reserved, including the broadcast address
+	mustParseCIDR("64:ff9b:1::/48"), // local-use nat64
+}
+
+// addresses in the well-known nat64 prefix reach the ipv4 address in their last four bytes
+var webhookNAT64Net = mustParseCIDR("64:ff9b::/96")
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return n
+}
+
+// reports whether webhooks may be delivered to the ip, users can't point them at
+// the loopback interface, the cloud metadata service or the internal network
+func publicIP(ip net.IP) bool {
+	// a nat64 gateway would connect to the embedded ipv4 address, so that one is checked
+	if webhookNAT64Net.Contains(ip) {
+		return publicIP(net.IP(ip.To16()[12:]))
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	for _, n := range webhookBlockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reports whether the url obviously points at a non-public host, used to reject webhooks
+// early, names resolving to such addresses are only caught when delivering
+func webhookHostBlocked(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && !publicIP(ip)
+}
+
+// returns the client webhooks are delivered with, it checks every address it connects to
+// after the host was resolved, so dns can't be used to sneak past the check
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !publicIP(ip) {
+				return errWebhookAddressBlocked
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// no proxy, it would be the one connecting to the webhook
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		// a redirect could be used to bounce the signed payload somewhere else
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// json payload sent to webhooks for every snippet event
+type snippetEventPayload struct {
+	Event     string         `json:"event"`
+	Timestamp time.Time      `json:"timestamp"`
+	Snippet   snippetPayload `json:"snippet"`
+}
+
+type snippetPayload struct {
+	ID      int       `json:"id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	URL     string    `json:"url"`
+}
+
+// queues the event for every webhook of the snippet owner that subscribed to it,
+// failures are only logged since they shouldn't fail the request that triggered the event
+func (app *application) emitSnippetEvent(event string, s *models.Snippet) {
+	if s.UserID == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(snippetEventPayload{
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Snippet: snippetPayload{
+			ID:      s.ID,
+			Title:   s.Title,
+			Content: s.Content,
+			Created: s.Created,
+			Expires: s.Expires,
+			URL:     fmt.Sprintf("%s/snippets/%d", app.baseURL, s.ID),
+		},
+	})
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	err = app.webhooks.Enqueue(s.UserID, event, payload)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// runs forever, periodically queueing expiry events, delivering due webhooks
+// and pruning old deliveries
+func (app *application) runWebhookWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.queueExpiredSnippetEvents()
+		app.deliverDueWebhooks()
+
+		err := app.webhooks.Prune(time.Now().Add(-webhookDeliveryRetention))
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+	}
+}
+
+func (app *application) queueExpiredSnippetEvents() {
+	snippets, err := app.snippets.Expired()
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	for _, s := range snippets {
+		app.emitSnippetEvent(models.EventSnippetExpired, s)
+	}
+}
+
+func (app *application) deliverDueWebhooks() {
+	deliveries, err := app.webhooks.Due(50)
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	for _, d := range deliveries {
+		app.deliverWebhook(d)
+	}
+}
+
+// sends a single delivery and records the outcome, scheduling a retry with
+// exponential backoff if it failed
+func (app *application) deliverWebhook(d *models.WebhookDelivery) {
+	code, err := sendWebhook(app.webhookClient, d)
+	if err == nil {
+		err = app.webhooks.MarkDelivered(d.ID, code)
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+		return
+	}
+
+	attempts := d.Attempts + 1
+	giveUp := attempts >= webhookMaxAttempts
+	next := time.Now().Add(webhookBackoff(attempts))
+
+	lastError := err.Error()
+	if code == 0 {
+		app.infoLog.Printf("webhook delivery %d failed: %v", d.ID, err)
+		lastError = webhookRequestFailed
+	}
+
+	err = app.webhooks.MarkFailed(d.ID, code, lastError, next, giveUp)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// posts the delivery payload and returns the response status code,
+// any non 2xx response is treated as an error
+func sendWebhook(client *http.Client, d *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GoSnipIt-Webhook")
+	req.Header.Set(webhookEventHeader, d.Event)
+	req.Header.Set(webhookDeliveryHeader, strconv.Itoa(d.ID))
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookPayload(d.Secret, d.Payload))
+
+	rs, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rs.Body.Close()
+	// drain a bounded amount of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(rs.Body, 64<<10))
+
+	if rs.StatusCode < 200 || rs.StatusCode > 299 {
+		return rs.StatusCode, fmt.Errorf("unexpected response status: %s", rs.Status)
+	}
+
+	return rs.StatusCode, nil
+}
+
+// returns the hex encoded HMAC-SHA256 of the payload keyed with the webhook secret
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// returns the delay before the next attempt after the given number of failed attempts
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff << (attempts - 1)
+	if backoff > webhookMaxBackoff || backoff <= 0 {
+		return webhookMaxBackoff
+	}
+
+	return backoff
+}
+
+// generates a random secret used to sign webhook payloads
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
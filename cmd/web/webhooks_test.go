@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+func TestSendWebhook(t *testing.T) {
+	const secret = "topsecret"
+	payload := []byte(`{"event":"snippet.created"}`)
+
+	var gotSignature, gotEvent string
+	var gotBody []byte
+
+	// fake receiver that records what it was sent
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSignature = r.Header.Get(webhookSignatureHeader)
+		gotEvent = r.Header.Get(webhookEventHeader)
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	d := &models.WebhookDelivery{
+		ID:      1,
+		Event:   models.EventSnippetCreated,
+		Payload: payload,
+		URL:     receiver.URL,
+		Secret:  secret,
+	}
+
+	code, err := sendWebhook(receiver.Client(), d)
+	assert.NilError(t, err)
+	assert.Equal(t, code, http.StatusNoContent)
+	assert.Equal(t, gotEvent, models.EventSnippetCreated)
+	assert.Equal(t, string(gotBody), string(payload))
+	// hmac-sha256 of the payload keyed with the secret
+	assert.Equal(t, gotSignature, "sha256="+signWebhookPayload(secret, payload))
+
+	t.Run("Error response", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer failing.Close()
+
+		d.URL = failing.URL
+		code, err := sendWebhook(failing.Client(), d)
+		assert.Equal(t, code, http.StatusInternalServerError)
+		assert.Equal(t, err != nil, true)
+	})
+}
+
+func TestWebhookClientBlocksInternalAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	d := &models.WebhookDelivery{ID: 1, Event: models.EventSnippetCreated, Payload: []byte(`{}`), URL: receiver.URL}
+
+	// the test server listens on the loopback interface
+	code, err := sendWebhook(newWebhookClient(), d)
+	assert.Equal(t, code, 0)
+	assert.Equal(t, errors.Is(err, errWebhookAddressBlocked), true)
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"64:ff9b::a00:1", false},       // 10.0.0.1 through nat64
+		{"64:ff9b::a9fe:a9fe", false},   // the metadata service through nat64
+		{"64:ff9b::5db8:d822", true},    // 93.184.216.34 through nat64
+		{"64:ff9b:1::5db8:d822", false}, // local-use nat64
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, publicIP(net.ParseIP(tt.ip)), tt.want)
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	assert.Equal(t, webhookBackoff(1), 30*time.Second)
+	assert.Equal(t, webhookBackoff(2), time.Minute)
+	assert.Equal(t, webhookBackoff(4), 4*time.Minute)
+	assert.Equal(t, webhookBackoff(50), webhookMaxBackoff)
+}
+
+func TestAccountWebhooks(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/account/webhooks")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	t.Run("List", func(t *testing.T) {
+		code, _, body := ts.get(t, "/account/webhooks")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "https://example.com/hook")
+	})
+
+	t.Run("Delivery log", func(t *testing.T) {
+		code, _, body := ts.get(t, "/account/webhooks/1")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "mocksecret")
+		assert.StringContains(t, body, "<td>204</td>")
+	})
+
+	t.Run("Not owned", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/account/webhooks/2")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	_, _, body := ts.get(t, "/account/webhooks")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		url      string
+		events   []string
+		wantCode int
+	}{
+		{"Valid", "https://chat.example.com/hook", []string{models.EventSnippetCreated}, http.StatusSeeOther},
+		{"Invalid URL", "ftp://example.com", []string{models.EventSnippetCreated}, http.StatusUnprocessableEntity},
+		{"Localhost", "http://localhost:8080/hook", []string{models.EventSnippetCreated}, http.StatusUnprocessableEntity},
+		{"Metadata service", "http://169.254.169.254/latest/meta-data", []string{models.EventSnippetCreated}, http.StatusUnprocessableEntity},
+		{"Private address", "http://[fd00::1]/hook", []string{models.EventSnippetCreated}, http.StatusUnprocessableEntity},
+		{"No events", "https://chat.example.com/hook", nil, http.StatusUnprocessableEntity},
+		{"Unknown event", "https://chat.example.com/hook", []string{"user.created"}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("url", tt.url)
+			for _, e := range tt.events {
+				form.Add("events", e)
+			}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, "/account/webhooks", form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+// Mailer sends emails rendered from one of the embedded templates. Every template
+// defines a "subject" and a "plainBody" block.
+type Mailer interface {
+	Send(recipient, templateFile string, data any) error
+}
+
+// renders the subject and body of the template with the given data
+func render(templateFile string, data any) (string, string, error) {
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return "", "", err
+	}
+
+	body := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(body, "plainBody", data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
+
+// builds a plain text rfc 5322 message
+func message(sender, recipient, subject, body string) []byte {
+	msg := new(bytes.Buffer)
+
+	fmt.Fprintf(msg, "From: %s\r\n", sender)
+	fmt.Fprintf(msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return msg.Bytes()
+}
+
+// delivers mail through an smtp server, upgrading the connection with STARTTLS when it's supported
+type SMTPMailer struct {
+	Addr     string // host:port of the smtp server
+	Username string
+	Password string
+	Sender   string // e.g. "GoSnipIt <no-reply@example.com>"
+}
+
+func (m *SMTPMailer) Send(recipient, templateFile string, data any) error {
+	subject, body, err := render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	from := m.Sender
+	if start, end := strings.Index(from, "<"), strings.Index(from, ">"); start != -1 && end > start {
+		from = from[start+1 : end]
+	}
+
+	return smtp.SendMail(m.Addr, auth, from, []string{recipient}, message(m.Sender, recipient, subject, body))
+}
+
+// writes every mail to w instead of sending it, meant for development and tests
+type LogMailer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	sender string
+}
+
+func NewLogMailer(w io.Writer, sender string) *LogMailer {
+	return &LogMailer{w: w, sender: sender}
+}
+
+func (m *LogMailer) Send(recipient, templateFile string, data any) error {
+	subject, body, err := render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err = fmt.Fprintf(m.w, "%s\r\n\r\n", message(m.sender, recipient, subject, body))
+	return err
+}
@@ -0,0 +1,24 @@
+package mailer
+
+import (
+	"bytes"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+func TestLogMailer(t *testing.T) {
+	buf := new(bytes.Buffer)
+	m := NewLogMailer(buf, "GoSnipIt <no-reply@example.com>")
+
+	err := m.Send("mocky@example.com", "verify_email.tmpl", map[string]any{
+		"Name": "Mocky",
+		"URL":  "https://gosnipit.test/user/verify/abc",
+	})
+	assert.NilError(t, err)
+
+	out := buf.String()
+	assert.StringContains(t, out, "To: mocky@example.com\r\n")
+	assert.StringContains(t, out, "Subject: Verify your GoSnipIt email address\r\n")
+	assert.StringContains(t, out, "https://gosnipit.test/user/verify/abc")
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// prefix of every api token, makes leaked tokens easy to recognize
+const APITokenPrefix = "snip_"
+
+type APITokenModelInterface interface {
+	Insert(userID int, name string) (string, error)
+	Authenticate(token string) (int, error)
+	ForUser(userID int) ([]*APIToken, error)
+	Delete(id, userID int) error
+	DeleteAllForUser(userID int) error
+}
+
+// Represents a personal api token, only a hash of the token itself is stored
+type APIToken struct {
+	ID       int
+	UserID   int
+	Name     string
+	Created  time.Time
+	LastUsed time.Time // zero if the token was never used
+}
+
+type APITokenModel struct {
+	DB *sql.DB
+}
+
+// creates a new token for the user and returns it in plain text,
+// this is the only time the token is available
+func (m *APITokenModel) Insert(userID int, name string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	token = APITokenPrefix + token
+
+	query := `INSERT INTO api_tokens (user_id, name, token_hash, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(query, userID, name, hashToken(token))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// returns the id of the user owning the token and records its usage
+func (m *APITokenModel) Authenticate(token string) (int, error) {
+	var id, userID int
+
+	query := `SELECT id, user_id FROM api_tokens WHERE token_hash = ?`
+
+	err := m.DB.QueryRow(query, hashToken(token)).Scan(&id, &userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	_, err = m.DB.Exec(`UPDATE api_tokens SET last_used = UTC_TIMESTAMP() WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (m *APITokenModel) ForUser(userID int) ([]*APIToken, error) {
+	query := `SELECT id, user_id, name, created, last_used FROM api_tokens
+	WHERE user_id = ? ORDER BY created DESC`
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := []*APIToken{}
+
+	for rows.Next() {
+		t := &APIToken{}
+		var lastUsed sql.NullTime
+
+		err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+
+		t.LastUsed = lastUsed.Time
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (m *APITokenModel) Delete(id, userID int) error {
+	query := `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// revokes every token of the user
+func (m *APITokenModel) DeleteAllForUser(userID int) error {
+	_, err := m.DB.Exec(`DELETE FROM api_tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+// returns the hex encoded sha256 of the token, tokens have enough entropy
+// that a slow password hash isn't needed
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// security relevant actions recorded in the audit log
+const (
+	AuditSignup           = "signup"
+	AuditLogin            = "login"
+	AuditLoginRemembered  = "login_remembered" // logged in again by a "remember me" token
+	AuditLoginFailed      = "login_failed"
+	AuditLogout           = "logout"
+	AuditPasswordChange   = "password_change"
+	AuditPasswordReset    = "password_reset"
+	AuditEmailChange      = "email_change"
+	AuditTokenCreate      = "token_create"
+	AuditTokenRevoke      = "token_revoke"
+	AuditSessionRevoke    = "session_revoke"
+	AuditRememberReused   = "remember_token_reused"
+	AuditTwoFactorEnable  = "two_factor_enable"
+	AuditTwoFactorDisable = "two_factor_disable"
+	AuditRecoveryCodes    = "recovery_codes_regenerate"
+	AuditAccountSuspend   = "account_suspend"
+	AuditAccountUnsuspend = "account_unsuspend"
+	AuditRoleChange       = "role_change"
+	AuditAccountDeletion  = "account_deletion"
+)
+
+// all audit actions, in the order they're offered as filters
+var AuditActions = []string{
+	AuditSignup, AuditLogin, AuditLoginRemembered, AuditLoginFailed, AuditLogout, AuditPasswordChange,
+	AuditPasswordReset, AuditEmailChange, AuditTokenCreate, AuditTokenRevoke, AuditSessionRevoke,
+	AuditRememberReused, AuditTwoFactorEnable, AuditTwoFactorDisable, AuditRecoveryCodes,
+	AuditAccountSuspend, AuditAccountUnsuspend, AuditRoleChange, AuditAccountDeletion,
+}
+
+type AuditModelInterface interface {
+	Insert(event *AuditEvent) error
+	ForUser(userID, limit int) ([]*AuditEvent, error)
+	Search(filter AuditFilter, limit int) ([]*AuditEvent, error)
+}
+
+// Represents a security relevant event of an account
+type AuditEvent struct {
+	ID        int
+	UserID    int    // 0 for failed logins with an unknown email address
+	UserEmail string // current email address of the user, empty if there's no such account
+	Action    string
+	IP        string
+	UserAgent string
+	Detail    string // e.g. the name of a created api token
+	Created   time.Time
+}
+
+// narrows down an audit log search, zero values match everything
+type AuditFilter struct {
+	UserID int
+	Action string
+	IP     string
+	Since  time.Time
+	Until  time.Time
+}
+
+// append-only log of security relevant events, events are kept when accounts are deleted
+// but lose the ip address, user agent and email addresses
+type AuditModel struct {
+	DB *sql.DB
+}
+
+func (m *AuditModel) Insert(event *AuditEvent) error {
+	if len(event.UserAgent) > 255 {
+		event.UserAgent = event.UserAgent[:255]
+	}
+
+	if len(event.Detail) > 255 {
+		event.Detail = event.Detail[:255]
+	}
+
+	query := `INSERT INTO audit_events (user_id, action, ip, user_agent, detail, created)
+	VALUES(NULLIF(?, 0), ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(query, event.UserID, event.Action, event.IP, event.UserAgent, event.Detail)
+	return err
+}
+
+// returns the most recent events of the user, newest first
+func (m *AuditModel) ForUser(userID, limit int) ([]*AuditEvent, error) {
+	return m.Search(AuditFilter{UserID: userID}, limit)
+}
+
+// returns the most recent events matching the filter, newest first
+func (m *AuditModel) Search(filter AuditFilter, limit int) ([]*AuditEvent, error) {
+	conditions := []string{"1 = 1"}
+	args := []any{}
+
+	if filter.UserID != 0 {
+		conditions = append(conditions, "a.user_id = ?")
+		args = append(args, filter.UserID)
+	}
+
+	if filter.Action != "" {
+		conditions = append(conditions, "a.action = ?")
+		args = append(args, filter.Action)
+	}
+
+	if filter.IP != "" {
+		conditions = append(conditions, "a.ip = ?")
+		args = append(args, filter.IP)
+	}
+
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "a.created >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "a.created < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	query := `SELECT a.id, COALESCE(a.user_id, 0), COALESCE(u.email, ''), a.action, a.ip, a.user_agent, a.detail, a.created
+	FROM audit_events a LEFT JOIN users u ON u.id = a.user_id
+	WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY a.id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+
+	for rows.Next() {
+		e := &AuditEvent{}
+
+		err = rows.Scan(&e.ID, &e.UserID, &e.UserEmail, &e.Action, &e.IP, &e.UserAgent, &e.Detail, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
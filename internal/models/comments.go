@@ -0,0 +1,200 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type CommentModelInterface interface {
+	Insert(snippetID, userID, parentID, line int, body string) (int, error)
+	Get(id int) (*Comment, error)
+	ForSnippet(snippetID int) ([]*Comment, error)
+	Update(id, userID int, body string) error
+	Delete(id int) error
+}
+
+// Represents a comment on a snippet, replies belong to the thread of a top-level
+// comment and share its line
+type Comment struct {
+	ID             int
+	SnippetID      int
+	UserID         int    // 0 once the author deleted their account
+	Author         string // name of the author, empty if there is none
+	AuthorUsername string
+	ParentID       int    // 0 for comments that start a thread
+	Line           int    // 0 for comments on the whole snippet
+	Body           string // empty once the comment is deleted
+	Deleted        bool   // deleted comments with replies are kept so the thread still makes sense
+	Created        time.Time
+	Updated        time.Time
+}
+
+// reports whether the comment was changed after it was posted
+func (c *Comment) Edited() bool {
+	return c.Updated.After(c.Created)
+}
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+func (m *CommentModel) Insert(snippetID, userID, parentID, line int, body string) (int, error) {
+	query := `INSERT INTO comments (snippet_id, user_id, parent_id, line, body, created, updated)
+	VALUES(?, ?, NULLIF(?, 0), ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(query, snippetID, userID, parentID, line, body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func (m *CommentModel) Get(id int) (*Comment, error) {
+	query := `SELECT c.id, c.snippet_id, COALESCE(c.user_id, 0), COALESCE(u.name, ''), COALESCE(u.username, ''),
+	COALESCE(c.parent_id, 0), c.line, c.body, c.deleted, c.created, c.updated
+	FROM comments c LEFT JOIN users u ON u.id = c.user_id WHERE c.id = ?`
+
+	c := &Comment{}
+
+	err := m.DB.QueryRow(query, id).Scan(&c.ID, &c.SnippetID, &c.UserID, &c.Author, &c.AuthorUsername,
+		&c.ParentID, &c.Line, &c.Body, &c.Deleted, &c.Created, &c.Updated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// returns all comments on the snippet, oldest first
+func (m *CommentModel) ForSnippet(snippetID int) ([]*Comment, error) {
+	query := `SELECT c.id, c.snippet_id, COALESCE(c.user_id, 0), COALESCE(u.name, ''), COALESCE(u.username, ''),
+	COALESCE(c.parent_id, 0), c.line, c.body, c.deleted, c.created, c.updated
+	FROM comments c LEFT JOIN users u ON u.id = c.user_id
+	WHERE c.snippet_id = ? ORDER BY c.created, c.id`
+
+	rows, err := m.DB.Query(query, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c := &Comment{}
+
+		err = rows.Scan(&c.ID, &c.SnippetID, &c.UserID, &c.Author, &c.AuthorUsername,
+			&c.ParentID, &c.Line, &c.Body, &c.Deleted, &c.Created, &c.Updated)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// changes the body of a comment written by the given user
+func (m *CommentModel) Update(id, userID int, body string) error {
+	query := `UPDATE comments SET body = ?, updated = UTC_TIMESTAMP() WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(query, body, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	// mysql reports zero affected rows when the values didn't change,
+	// so check whether the comment actually exists for this user
+	var exists bool
+
+	query = `SELECT EXISTS(SELECT true FROM comments WHERE id = ? AND user_id = ?)`
+
+	err = m.DB.QueryRow(query, id, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// deletes a comment, comments with replies only lose their body and author so the replies
+// of others are kept, the thread goes away once its last reply is deleted
+func (m *CommentModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var parentID int
+	var replies bool
+
+	query := `SELECT COALESCE(parent_id, 0), EXISTS(SELECT true FROM comments r WHERE r.parent_id = c.id)
+	FROM comments c WHERE c.id = ? AND NOT c.deleted FOR UPDATE`
+
+	err = tx.QueryRow(query, id).Scan(&parentID, &replies)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	if replies {
+		_, err = tx.Exec(`UPDATE comments SET body = '', user_id = NULL, deleted = TRUE WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	_, err = tx.Exec(`DELETE FROM comments WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	if parentID != 0 {
+		err = tx.QueryRow(`SELECT EXISTS(SELECT true FROM comments WHERE parent_id = ?)`, parentID).Scan(&replies)
+		if err != nil {
+			return err
+		}
+
+		if !replies {
+			_, err = tx.Exec(`DELETE FROM comments WHERE id = ? AND deleted`, parentID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -6,4 +6,9 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrDuplicateUsername  = errors.New("models: duplicate username")
+	ErrInvalidToken       = errors.New("models: invalid or expired token")
+	ErrTokenReused        = errors.New("models: token reused after it was rotated")
+	ErrDuplicateSlug      = errors.New("models: duplicate team slug")
+	ErrLastOwner          = errors.New("models: a team needs at least one owner")
 )
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+type IdentityModelInterface interface {
+	Get(issuer, subject string) (int, error)
+	Insert(userID int, issuer, subject string) error
+	Linked(userID int) (bool, error)
+}
+
+// links accounts to identities of an openid connect provider,
+// an identity is the subject claim which is only unique per issuer
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+// returns the id of the user linked to the identity
+func (m *IdentityModel) Get(issuer, subject string) (int, error) {
+	var userID int
+
+	query := `SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?`
+
+	err := m.DB.QueryRow(query, issuer, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+func (m *IdentityModel) Insert(userID int, issuer, subject string) error {
+	query := `INSERT INTO user_identities (user_id, issuer, subject, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(query, userID, issuer, subject)
+	return err
+}
+
+// reports whether the user has an identity linked to their account, i.e. whether they
+// can confirm who they are with the provider instead of a password
+func (m *IdentityModel) Linked(userID int) (bool, error) {
+	var linked bool
+
+	query := `SELECT EXISTS(SELECT true FROM user_identities WHERE user_id = ?)`
+
+	err := m.DB.QueryRow(query, userID).Scan(&linked)
+	return linked, err
+}
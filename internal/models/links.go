@@ -0,0 +1,106 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ShareLinkModelInterface interface {
+	Insert(snippetID, createdBy int, expiry time.Time) (int, error)
+	Get(id int) (*ShareLink, error)
+	ForSnippet(snippetID int) ([]*ShareLink, error)
+	Delete(id, snippetID int) error
+}
+
+// Represents a signed link that lets anyone read a snippet until it expires,
+// the signature is never stored, revoking a link deletes it
+type ShareLink struct {
+	ID        int
+	SnippetID int
+	CreatedBy int
+	Created   time.Time
+	Expiry    time.Time
+}
+
+type ShareLinkModel struct {
+	DB *sql.DB
+}
+
+func (m *ShareLinkModel) Insert(snippetID, createdBy int, expiry time.Time) (int, error) {
+	query := `INSERT INTO share_links (snippet_id, created_by, created, expiry)
+	VALUES(?, ?, UTC_TIMESTAMP(), ?)`
+
+	result, err := m.DB.Exec(query, snippetID, createdBy, expiry.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// returns the link if it exists and hasn't expired yet
+func (m *ShareLinkModel) Get(id int) (*ShareLink, error) {
+	l := &ShareLink{}
+
+	query := `SELECT id, snippet_id, created_by, created, expiry FROM share_links
+	WHERE id = ? AND expiry > UTC_TIMESTAMP()`
+
+	err := m.DB.QueryRow(query, id).Scan(&l.ID, &l.SnippetID, &l.CreatedBy, &l.Created, &l.Expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// returns the outstanding links of the snippet, the ones expiring first come first
+func (m *ShareLinkModel) ForSnippet(snippetID int) ([]*ShareLink, error) {
+	query := `SELECT id, snippet_id, created_by, created, expiry FROM share_links
+	WHERE snippet_id = ? AND expiry > UTC_TIMESTAMP() ORDER BY expiry`
+
+	rows, err := m.DB.Query(query, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	links := []*ShareLink{}
+
+	for rows.Next() {
+		l := &ShareLink{}
+
+		err = rows.Scan(&l.ID, &l.SnippetID, &l.CreatedBy, &l.Created, &l.Expiry)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// revokes a link of the snippet
+func (m *ShareLinkModel) Delete(id, snippetID int) error {
+	result, err := m.DB.Exec(`DELETE FROM share_links WHERE id = ? AND snippet_id = ?`, id, snippetID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
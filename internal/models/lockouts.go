@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type LoginLockoutModelInterface interface {
+	Insert(userID int, ip string, until time.Time) error
+	ForUser(userID, limit int) ([]*LoginLockout, error)
+}
+
+// Represents a temporary lockout of an account after too many failed logins
+type LoginLockout struct {
+	ID      int
+	UserID  int
+	IP      string // address of the last failed attempt
+	Created time.Time
+	Until   time.Time
+}
+
+// records lockouts so account owners can see that someone tried to guess their password
+type LoginLockoutModel struct {
+	DB *sql.DB
+}
+
+func (m *LoginLockoutModel) Insert(userID int, ip string, until time.Time) error {
+	query := `INSERT INTO login_lockouts (user_id, ip, created, until)
+	VALUES(?, ?, UTC_TIMESTAMP(), ?)`
+
+	_, err := m.DB.Exec(query, userID, ip, until.UTC())
+	return err
+}
+
+// returns the most recent lockouts of the user, newest first
+func (m *LoginLockoutModel) ForUser(userID, limit int) ([]*LoginLockout, error) {
+	query := `SELECT id, user_id, ip, created, until FROM login_lockouts
+	WHERE user_id = ? ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	lockouts := []*LoginLockout{}
+
+	for rows.Next() {
+		l := &LoginLockout{}
+
+		err = rows.Scan(&l.ID, &l.UserID, &l.IP, &l.Created, &l.Until)
+		if err != nil {
+			return nil, err
+		}
+
+		lockouts = append(lockouts, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lockouts, nil
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+const MockAPIToken = models.APITokenPrefix + "mocktoken"
+
+type APITokenModel struct{}
+
+func (m *APITokenModel) Insert(userID int, name string) (string, error) {
+	return MockAPIToken, nil
+}
+
+func (m *APITokenModel) Authenticate(token string) (int, error) {
+	if token == MockAPIToken {
+		return 1, nil
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
+
+func (m *APITokenModel) ForUser(userID int) ([]*models.APIToken, error) {
+	if userID != 1 {
+		return []*models.APIToken{}, nil
+	}
+
+	return []*models.APIToken{
+		{ID: 1, UserID: 1, Name: "Laptop", Created: time.Now()},
+	}, nil
+}
+
+func (m *APITokenModel) Delete(id, userID int) error {
+	if id == 1 && userID == 1 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *APITokenModel) DeleteAllForUser(userID int) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package mocks
+
+import (
+	"sync"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// keeps the inserted events in memory so tests can check what was recorded
+type AuditModel struct {
+	mu     sync.Mutex
+	events []*models.AuditEvent
+}
+
+func (m *AuditModel) Insert(event *models.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := *event
+	e.ID = len(m.events) + 1
+
+	if user, err := findMockUser(func(u models.User) bool { return u.ID == e.UserID }); err == nil {
+		e.UserEmail = user.Email
+	}
+	m.events = append(m.events, &e)
+
+	return nil
+}
+
+func (m *AuditModel) ForUser(userID, limit int) ([]*models.AuditEvent, error) {
+	return m.Search(models.AuditFilter{UserID: userID}, limit)
+}
+
+func (m *AuditModel) Search(filter models.AuditFilter, limit int) ([]*models.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := []*models.AuditEvent{}
+
+	for i := len(m.events) - 1; i >= 0 && len(events) < limit; i-- {
+		e := m.events[i]
+
+		if (filter.UserID == 0 || e.UserID == filter.UserID) &&
+			(filter.Action == "" || e.Action == filter.Action) &&
+			(filter.IP == "" || e.IP == filter.IP) {
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
+
+// returns the actions of all recorded events in the order they happened
+func (m *AuditModel) Actions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	actions := []string{}
+	for _, e := range m.events {
+		actions = append(actions, e.Action)
+	}
+
+	return actions
+}
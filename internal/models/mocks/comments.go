@@ -0,0 +1,67 @@
+package mocks
+
+import (
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// comments on snippet 1: user 1 commented on the whole snippet, user 5 on line 1
+// and user 1 replied to that, the comment starting another thread was deleted
+// but user 5's reply to it was kept
+type CommentModel struct{}
+
+var mockComments = []models.Comment{
+	{ID: 1, SnippetID: 1, UserID: 1, Author: "Mocky McMockface", AuthorUsername: "mocky", Body: "Looks good to me"},
+	{ID: 2, SnippetID: 1, UserID: 5, Author: "Admin McMockface", AuthorUsername: "admin-mock", Line: 1, Body: "Why this line?"},
+	{ID: 3, SnippetID: 1, UserID: 1, Author: "Mocky McMockface", AuthorUsername: "mocky", ParentID: 2, Line: 1, Body: "Because it works"},
+	{ID: 4, SnippetID: 1, Deleted: true},
+	{ID: 5, SnippetID: 1, UserID: 5, Author: "Admin McMockface", AuthorUsername: "admin-mock", ParentID: 4, Body: "Still worth a read"},
+}
+
+func (m *CommentModel) Insert(snippetID, userID, parentID, line int, body string) (int, error) {
+	return 6, nil
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	for _, c := range mockComments {
+		if c.ID == id {
+			c.Created = time.Now()
+			c.Updated = c.Created
+			return &c, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	comments := []*models.Comment{}
+
+	for _, c := range mockComments {
+		if c.SnippetID == snippetID {
+			comment, _ := m.Get(c.ID)
+			comments = append(comments, comment)
+		}
+	}
+
+	return comments, nil
+}
+
+func (m *CommentModel) Update(id, userID int, body string) error {
+	c, err := m.Get(id)
+	if err != nil || c.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *CommentModel) Delete(id int) error {
+	c, err := m.Get(id)
+	if err == nil && c.Deleted {
+		return models.ErrNoRecord
+	}
+
+	return err
+}
@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// subject of an identity that is linked to user 1 for any issuer
+const MockIdentitySubject = "mock-subject"
+
+type IdentityModel struct{}
+
+func (m *IdentityModel) Get(issuer, subject string) (int, error) {
+	if subject == MockIdentitySubject {
+		return 1, nil
+	}
+
+	return 0, models.ErrNoRecord
+}
+
+func (m *IdentityModel) Insert(userID int, issuer, subject string) error {
+	return nil
+}
+
+func (m *IdentityModel) Linked(userID int) (bool, error) {
+	return userID == 1, nil
+}
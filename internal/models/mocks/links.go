@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// expiry of share link 1 to snippet 4, the only outstanding link
+var MockShareLinkExpiry = time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+type ShareLinkModel struct{}
+
+var mockShareLink = models.ShareLink{ID: 1, SnippetID: 4, CreatedBy: 1}
+
+func (m *ShareLinkModel) Insert(snippetID, createdBy int, expiry time.Time) (int, error) {
+	return 2, nil
+}
+
+func (m *ShareLinkModel) Get(id int) (*models.ShareLink, error) {
+	if id != mockShareLink.ID {
+		return nil, models.ErrNoRecord
+	}
+
+	l := mockShareLink
+	l.Created = time.Now()
+	l.Expiry = MockShareLinkExpiry
+
+	return &l, nil
+}
+
+func (m *ShareLinkModel) ForSnippet(snippetID int) ([]*models.ShareLink, error) {
+	if snippetID != mockShareLink.SnippetID {
+		return []*models.ShareLink{}, nil
+	}
+
+	l, _ := m.Get(mockShareLink.ID)
+
+	return []*models.ShareLink{l}, nil
+}
+
+func (m *ShareLinkModel) Delete(id, snippetID int) error {
+	if id != mockShareLink.ID || snippetID != mockShareLink.SnippetID {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// user 1 was locked out once
+type LoginLockoutModel struct{}
+
+func (m *LoginLockoutModel) Insert(userID int, ip string, until time.Time) error {
+	return nil
+}
+
+func (m *LoginLockoutModel) ForUser(userID, limit int) ([]*models.LoginLockout, error) {
+	if userID != 1 {
+		return []*models.LoginLockout{}, nil
+	}
+
+	return []*models.LoginLockout{{
+		ID:      1,
+		UserID:  1,
+		IP:      "198.51.100.7",
+		Created: time.Now().Add(-time.Hour),
+		Until:   time.Now().Add(-45 * time.Minute),
+	}}, nil
+}
@@ -0,0 +1,39 @@
+package mocks
+
+import (
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+const (
+	// remember token of the user with id 1, rotating it returns the same token
+	MockRememberToken = "mockremember-1"
+	// remember token of the user with id 1 that was rotated already, presenting it counts as theft
+	MockStolenRememberToken = "mockremember-stolen"
+)
+
+type RememberTokenModel struct{}
+
+func (m *RememberTokenModel) New(userID int, ttl time.Duration) (string, error) {
+	return MockRememberToken, nil
+}
+
+func (m *RememberTokenModel) Rotate(token string, ttl time.Duration) (int, string, error) {
+	switch token {
+	case MockRememberToken:
+		return 1, MockRememberToken, nil
+	case MockStolenRememberToken:
+		return 1, "", models.ErrTokenReused
+	default:
+		return 0, "", models.ErrInvalidToken
+	}
+}
+
+func (m *RememberTokenModel) Delete(token string) error {
+	return nil
+}
+
+func (m *RememberTokenModel) DeleteAllForUser(userID int, exceptToken string) error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package mocks
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// session tokens are "mocksession-<user id>", every user has the sessions 1 (current) and 2
+type SessionModel struct{}
+
+func (m *SessionModel) Insert(userID int, ip, userAgent string) (string, error) {
+	return "mocksession-" + strconv.Itoa(userID), nil
+}
+
+func (m *SessionModel) Get(token string) (*models.Session, error) {
+	userID, err := strconv.Atoi(strings.TrimPrefix(token, "mocksession-"))
+	if err != nil {
+		return nil, models.ErrNoRecord
+	}
+
+	return &models.Session{
+		ID:        1,
+		UserID:    userID,
+		Created:   time.Now(),
+		LastSeen:  time.Now(),
+		IP:        "127.0.0.1",
+		UserAgent: "Go-http-client/1.1",
+	}, nil
+}
+
+func (m *SessionModel) Touch(id int, ip string) error {
+	return nil
+}
+
+func (m *SessionModel) ForUser(userID int) ([]*models.Session, error) {
+	current, _ := m.Get("mocksession-" + strconv.Itoa(userID))
+
+	return []*models.Session{current, {
+		ID:        2,
+		UserID:    userID,
+		Created:   time.Now().Add(-time.Hour),
+		LastSeen:  time.Now().Add(-time.Hour),
+		IP:        "192.0.2.1",
+		UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/113.0",
+	}}, nil
+}
+
+func (m *SessionModel) Delete(id, userID int) error {
+	if id == 1 || id == 2 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *SessionModel) DeleteAllForUser(userID, exceptID int) error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package mocks
+
+import (
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// snippet 4 is shared read-only with user 2 and read-write with user 5
+type ShareModel struct{}
+
+var mockShares = []models.Share{
+	{SnippetID: 4, UserID: 5, Name: "Admin McMockface", Username: "admin-mock", Email: "admin@example.com", Access: models.AccessWrite},
+	{SnippetID: 4, UserID: 2, Name: "Unverified McMockface", Username: "unverified", Email: "unverified@example.com", Access: models.AccessRead},
+}
+
+func (m *ShareModel) Upsert(snippetID, userID int, access string) error {
+	return nil
+}
+
+func (m *ShareModel) Access(snippetID, userID int) (string, error) {
+	for _, s := range mockShares {
+		if s.SnippetID == snippetID && s.UserID == userID {
+			return s.Access, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (m *ShareModel) ForSnippet(snippetID int) ([]*models.Share, error) {
+	shares := []*models.Share{}
+
+	for _, s := range mockShares {
+		if s.SnippetID == snippetID {
+			share := s
+			share.Created = time.Now()
+			shares = append(shares, &share)
+		}
+	}
+
+	return shares, nil
+}
+
+func (m *ShareModel) Delete(snippetID, userID int) error {
+	access, _ := m.Access(snippetID, userID)
+	if access == "" {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
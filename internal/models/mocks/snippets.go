@@ -7,22 +7,91 @@ import (
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "Some mock title",
-	Content: "Some mock content...",
-	Created: time.Now(),
+	ID:             1,
+	UserID:         1,
+	Author:         "Mocky McMockface",
+	AuthorUsername: "mocky",
+	Title:          "Some mock title",
+	Content:        "Some mock content...",
+	Created:        time.Now(),
+	Expires:        time.Now().Add(7 * 24 * time.Hour),
+	Stars:          1,
+}
+
+// expired snippet of user 1, only returned by StarredBy
+var mockExpiredSnippet = &models.Snippet{
+	ID:             5,
+	UserID:         1,
+	Author:         "Mocky McMockface",
+	AuthorUsername: "mocky",
+	Title:          "Some expired title",
+	Content:        "Some expired content...",
+	Created:        time.Now().Add(-8 * 24 * time.Hour),
+	Expires:        time.Now().Add(-24 * time.Hour),
+}
+
+// private snippet of the mocked team, written by user 1
+var mockTeamSnippet = &models.Snippet{
+	ID:             3,
+	UserID:         1,
+	Author:         "Mocky McMockface",
+	AuthorUsername: "mocky",
+	TeamID:         1,
+	Team:           "Mock Squad",
+	TeamSlug:       "mock-squad",
+	Private:        true,
+	Title:          "Some team secret",
+	Content:        "Some private team content...",
+	Created:        time.Now(),
+}
+
+// private snippet of the mocked team, written by user 2 who is no longer a member
+var mockFormerMemberSnippet = &models.Snippet{
+	ID:       6,
+	UserID:   2,
+	Author:   "Unverified McMockface",
+	TeamID:   1,
+	Team:     "Mock Squad",
+	TeamSlug: "mock-squad",
+	Private:  true,
+	Title:    "Some orphaned team secret",
+	Content:  "Some private team content...",
+	Created:  time.Now(),
+}
+
+// private snippet of user 1, shared with users 2 and 5, see ShareModel
+var mockPrivateSnippet = &models.Snippet{
+	ID:             4,
+	UserID:         1,
+	Author:         "Mocky McMockface",
+	AuthorUsername: "mocky",
+	Private:        true,
+	Title:          "Some shared secret",
+	Content:        "Some private content...",
+	Created:        time.Now(),
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(userID int, title, content, language string, expires int, private bool) (int, error) {
+	// the inserted snippet is read back by the handlers, so return the id of the mock snippet
+	return 1, nil
+}
+
+func (m *SnippetModel) InsertForTeam(userID, teamID int, title, content, language string, expires int, private bool) (int, error) {
+	return 3, nil
 }
 
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockTeamSnippet, nil
+	case 4:
+		return mockPrivateSnippet, nil
+	case 6:
+		return mockFormerMemberSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
@@ -31,3 +100,87 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) ByUser(userID, limit int) ([]*models.Snippet, error) {
+	if userID == 1 {
+		return []*models.Snippet{mockSnippet}, nil
+	}
+
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) OwnedBy(userID, limit int) ([]*models.Snippet, error) {
+	if userID == 1 {
+		return []*models.Snippet{mockSnippet, mockTeamSnippet, mockPrivateSnippet}, nil
+	}
+
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) ByTeam(teamID, limit int) ([]*models.Snippet, error) {
+	if teamID == 1 {
+		return []*models.Snippet{mockTeamSnippet}, nil
+	}
+
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) SharedWith(userID, limit int) ([]*models.Snippet, error) {
+	if userID == 2 || userID == 5 {
+		return []*models.Snippet{mockPrivateSnippet}, nil
+	}
+
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) StarredBy(userID, limit int) ([]*models.Snippet, error) {
+	if userID == 1 {
+		return []*models.Snippet{mockSnippet, mockExpiredSnippet}, nil
+	}
+
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) Update(id, userID int, title, content string) error {
+	if (id == 1 || id == 3 || id == 4) && userID == 1 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Delete(id, userID int) error {
+	if (id == 1 || id == 3 || id == 4) && userID == 1 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) UpdateForTeam(id, teamID int, title, content string) error {
+	if (id == 3 || id == 6) && teamID == 1 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) DeleteForTeam(id, teamID int) error {
+	if (id == 3 || id == 6) && teamID == 1 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Expired() ([]*models.Snippet, error) {
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) DeleteAny(id int) error {
+	if id == 1 || id == 3 || id == 4 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
@@ -0,0 +1,17 @@
+package mocks
+
+import (
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+type StatsModel struct{}
+
+func (m *StatsModel) Get() (*models.Stats, error) {
+	return &models.Stats{
+		Users:         3,
+		VerifiedUsers: 2,
+		Snippets:      1,
+		Sessions:      2,
+		APITokens:     1,
+	}, nil
+}
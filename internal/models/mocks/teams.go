@@ -0,0 +1,166 @@
+package mocks
+
+import (
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+const (
+	// invitation to the mocked team for unverified@example.com, who is not a member yet
+	MockTeamInvitationToken = "mockinvitation"
+	// invitation to the mocked team for twofactor@example.com
+	MockOtherTeamInvitationToken = "mockinvitation-other"
+)
+
+// user 1 owns team 1 "mock-squad" and user 5 is a member of it, the slug "dupe" is taken
+type TeamModel struct{}
+
+var mockTeam = models.Team{ID: 1, Name: "Mock Squad", Slug: "mock-squad"}
+
+func (m *TeamModel) Insert(name, slug string, ownerID int) (int, error) {
+	if slug == "dupe" || slug == mockTeam.Slug {
+		return 0, models.ErrDuplicateSlug
+	}
+
+	return 2, nil
+}
+
+func (m *TeamModel) GetBySlug(slug string) (*models.Team, error) {
+	if slug != mockTeam.Slug {
+		return nil, models.ErrNoRecord
+	}
+
+	t := mockTeam
+	t.Created = time.Now()
+
+	return &t, nil
+}
+
+func (m *TeamModel) ForUser(userID int) ([]*models.Team, error) {
+	role, _ := m.Role(mockTeam.ID, userID)
+	if role == "" {
+		return []*models.Team{}, nil
+	}
+
+	t, _ := m.GetBySlug(mockTeam.Slug)
+	t.Role = role
+
+	return []*models.Team{t}, nil
+}
+
+func (m *TeamModel) Role(teamID, userID int) (string, error) {
+	if teamID != mockTeam.ID {
+		return "", nil
+	}
+
+	switch userID {
+	case 1:
+		return models.TeamRoleOwner, nil
+	case 5:
+		return models.TeamRoleMember, nil
+	default:
+		return "", nil
+	}
+}
+
+func (m *TeamModel) Members(teamID int) ([]*models.TeamMember, error) {
+	if teamID != mockTeam.ID {
+		return []*models.TeamMember{}, nil
+	}
+
+	return []*models.TeamMember{
+		{UserID: 1, Name: "Mocky McMockface", Username: "mocky", Email: "mocked@example.com", Role: models.TeamRoleOwner, Joined: time.Now()},
+		{UserID: 5, Name: "Admin McMockface", Username: "admin-mock", Email: "admin@example.com", Role: models.TeamRoleMember, Joined: time.Now()},
+	}, nil
+}
+
+func (m *TeamModel) SetRole(teamID, userID int, role string) error {
+	switch {
+	case teamID != mockTeam.ID:
+		return models.ErrNoRecord
+	case userID == 1 && role != models.TeamRoleOwner:
+		return models.ErrLastOwner
+	case userID == 1 || userID == 5:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *TeamModel) RemoveMember(teamID, userID int) error {
+	switch {
+	case teamID != mockTeam.ID:
+		return models.ErrNoRecord
+	case userID == 1:
+		return models.ErrLastOwner
+	case userID == 5:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *TeamModel) Delete(id int) error {
+	if id != mockTeam.ID {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *TeamModel) Invite(teamID, invitedBy int, email, role string, ttl time.Duration) (string, error) {
+	return MockTeamInvitationToken, nil
+}
+
+func (m *TeamModel) Invitations(teamID int) ([]*models.TeamInvitation, error) {
+	if teamID != mockTeam.ID {
+		return []*models.TeamInvitation{}, nil
+	}
+
+	i, _ := m.GetInvitation(MockOtherTeamInvitationToken)
+
+	return []*models.TeamInvitation{i}, nil
+}
+
+func (m *TeamModel) GetInvitation(token string) (*models.TeamInvitation, error) {
+	i := &models.TeamInvitation{
+		ID:        1,
+		TeamID:    mockTeam.ID,
+		TeamName:  mockTeam.Name,
+		TeamSlug:  mockTeam.Slug,
+		Role:      models.TeamRoleMember,
+		InvitedBy: 1,
+		Created:   time.Now(),
+		Expiry:    time.Now().Add(time.Hour),
+	}
+
+	switch token {
+	case MockTeamInvitationToken:
+		i.Email = "unverified@example.com"
+	case MockOtherTeamInvitationToken:
+		i.ID = 2
+		i.Email = "twofactor@example.com"
+	default:
+		return nil, models.ErrInvalidToken
+	}
+
+	return i, nil
+}
+
+func (m *TeamModel) AcceptInvitation(token string, userID int) (int, error) {
+	i, err := m.GetInvitation(token)
+	if err != nil {
+		return 0, err
+	}
+
+	return i.TeamID, nil
+}
+
+func (m *TeamModel) DeleteInvitation(id, teamID int) error {
+	if teamID == mockTeam.ID && (id == 1 || id == 2) {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
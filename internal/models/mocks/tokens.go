@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+// token accepted by Consume for any scope, issued to user 1
+const MockToken = "mocktoken"
+
+type TokenModel struct{}
+
+func (m *TokenModel) New(userID int, scope string, ttl time.Duration) (string, error) {
+	return MockToken, nil
+}
+
+func (m *TokenModel) Consume(scope, token string) (int, error) {
+	if token == MockToken {
+		return 1, nil
+	}
+
+	return 0, models.ErrInvalidToken
+}
+
+func (m *TokenModel) DeleteAllForUser(scope string, userID int) error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package mocks
+
+import (
+	"strings"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+const (
+	// totp code accepted by CheckCode for any user
+	MockTOTPCode = "123456"
+	// recovery code accepted by UseRecoveryCode for any user
+	MockRecoveryCode = "mock1-code1"
+	mockTOTPSecret   = "JBSWY3DPEHPK3PXP"
+)
+
+// user 3 has 2fa enabled, everyone else doesn't
+type TwoFactorModel struct{}
+
+func (m *TwoFactorModel) Secret(userID int) (string, error) {
+	return mockTOTPSecret, nil
+}
+
+func (m *TwoFactorModel) SetSecret(userID int, secret string) error {
+	return nil
+}
+
+func (m *TwoFactorModel) Enabled(userID int) (bool, error) {
+	return userID == 3, nil
+}
+
+func (m *TwoFactorModel) Enable(userID int) ([]string, error) {
+	return m.RegenerateRecoveryCodes(userID)
+}
+
+func (m *TwoFactorModel) Disable(userID int) error {
+	return nil
+}
+
+func (m *TwoFactorModel) CheckCode(userID int, code string) error {
+	if code == MockTOTPCode {
+		return nil
+	}
+
+	return models.ErrInvalidCredentials
+}
+
+func (m *TwoFactorModel) UseRecoveryCode(userID int, code string) error {
+	if strings.EqualFold(code, MockRecoveryCode) {
+		return nil
+	}
+
+	return models.ErrInvalidCredentials
+}
+
+func (m *TwoFactorModel) RecoveryCodesLeft(userID int) (int, error) {
+	return models.RecoveryCodeCount, nil
+}
+
+func (m *TwoFactorModel) RegenerateRecoveryCodes(userID int) ([]string, error) {
+	codes := make([]string, models.RecoveryCodeCount)
+	for i := range codes {
+		codes[i] = MockRecoveryCode
+	}
+
+	return codes, nil
+}
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"strings"
 	"time"
 
 	"gosnipit.ricci2511.dev/internal/models"
@@ -8,50 +9,194 @@ import (
 
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
-	// emulate a duplicate email error
-	switch email {
-	case "dupe@example.com":
-		return models.ErrDuplicateEmail
+func (m *UserModel) Insert(name, username, email, password string) (int, error) {
+	// emulate duplicate email and username errors
+	switch {
+	case email == "dupe@example.com":
+		return 0, models.ErrDuplicateEmail
+	case username == "dupe":
+		return 0, models.ErrDuplicateUsername
 	default:
-		return nil
+		return 4, nil
 	}
 }
 
+// the mocked users all share the password mocked1234:
+// user 1 is a regular user, user 2 hasn't verified their email address yet,
+// user 3 has two-factor authentication enabled, user 5 is an admin and user 6 is suspended
+var mockUsers = []models.User{
+	{ID: 1, Name: "Mocky McMockface", Username: "mocky", Email: "mocked@example.com", EmailVerified: true, Role: models.RoleUser},
+	{ID: 2, Name: "Unverified McMockface", Username: "unverified", Email: "unverified@example.com", Role: models.RoleUser},
+	{ID: 3, Name: "Twofactor McMockface", Username: "twofactor", Email: "twofactor@example.com", EmailVerified: true, Role: models.RoleUser},
+	{ID: 5, Name: "Admin McMockface", Username: "admin-mock", Email: "admin@example.com", EmailVerified: true, Role: models.RoleAdmin},
+	{ID: 6, Name: "Suspended McMockface", Username: "suspended", Email: "suspended@example.com", EmailVerified: true, Role: models.RoleUser, Suspended: true},
+}
+
+// returns a copy of the first mocked user matching the predicate
+func findMockUser(match func(u models.User) bool) (*models.User, error) {
+	for _, u := range mockUsers {
+		if match(u) {
+			u.Created = time.Now()
+			return &u, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
 func (m *UserModel) Authenticate(email, password string) (int, error) {
-	if email == "mocked@example.com" && password == "mocked1234" {
-		return 1, nil
+	u, err := m.GetByEmail(email)
+	if err != nil || password != "mocked1234" {
+		return 0, models.ErrInvalidCredentials
 	}
 
-	return 0, models.ErrInvalidCredentials
+	return u.ID, nil
 }
 
 func (m *UserModel) Exists(id int) (bool, error) {
+	_, err := m.Get(id)
+	return err == nil, nil
+}
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	return findMockUser(func(u models.User) bool { return u.ID == id })
+}
+
+func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+	if id == 1 && currentPassword == "mocked1234" && newPassword == "mocked5678" {
+		return nil
+	}
+
+	return models.ErrInvalidCredentials
+}
+
+const mockFeedToken = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func (m *UserModel) FeedToken(id int) (string, error) {
 	switch id {
 	case 1:
-		return true, nil
-	default:
-		return false, nil
+		return mockFeedToken, nil
+	case 2, 3, 5, 6:
+		return strings.Repeat("f", 64), nil
 	}
+
+	return "", models.ErrNoRecord
 }
 
-func (m *UserModel) Get(id int) (*models.User, error) {
-	if id == 1 {
-		return &models.User{
-			ID:      1,
-			Name:    "Mocky McMockface",
-			Email:   "mocked@example.com",
-			Created: time.Now(),
-		}, nil
+func (m *UserModel) ResetFeedToken(id int) (string, error) {
+	return m.FeedToken(id)
+}
+
+func (m *UserModel) GetByFeedToken(token string) (*models.User, error) {
+	if token == mockFeedToken {
+		return m.Get(1)
 	}
 
 	return nil, models.ErrNoRecord
 }
 
-func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
-	if id == 1 && currentPassword == "mocked1234" && newPassword == "mocked5678" {
+func (m *UserModel) SetEmailVerified(id int) error {
+	return nil
+}
+
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	return findMockUser(func(u models.User) bool { return u.Email == email })
+}
+
+func (m *UserModel) PasswordReset(id int, newPassword string) error {
+	if id == 1 {
 		return nil
 	}
 
-	return models.ErrInvalidCredentials
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) InsertSSO(name, username, email string) (int, error) {
+	return m.Insert(name, username, email, "")
+}
+
+func (m *UserModel) CheckPassword(id int, password string) error {
+	_, err := m.Get(id)
+	if err != nil || password != "mocked1234" {
+		return models.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+func (m *UserModel) ScheduleDeletion(id int, mode string, at time.Time) error {
+	_, err := m.Get(id)
+	return err
+}
+
+func (m *UserModel) CancelDeletion(id int) (bool, error) {
+	return false, nil
+}
+
+func (m *UserModel) DueDeletions() ([]int, error) {
+	return []int{}, nil
+}
+
+func (m *UserModel) DeletionMode(id int) (string, error) {
+	if _, err := m.Get(id); err != nil {
+		return "", err
+	}
+
+	return models.DeletionModeDelete, nil
+}
+
+func (m *UserModel) Delete(id int) error {
+	return nil
+}
+
+func (m *UserModel) RequestEmailChange(id int, password, email string) error {
+	if id != 1 || password != "mocked1234" {
+		return models.ErrInvalidCredentials
+	}
+
+	switch email {
+	case "dupe@example.com":
+		return models.ErrDuplicateEmail
+	}
+
+	if _, err := m.GetByEmail(email); err == nil {
+		return models.ErrDuplicateEmail
+	}
+
+	return nil
+}
+
+func (m *UserModel) ConfirmEmailChange(id int) (string, error) {
+	if id == 1 {
+		return "mocked@example.com", nil
+	}
+
+	return "", models.ErrNoRecord
+}
+
+func (m *UserModel) GetByUsername(username string) (*models.User, error) {
+	return findMockUser(func(u models.User) bool { return u.Username == username })
+}
+
+func (m *UserModel) SetRole(id int, role string) error {
+	_, err := m.Get(id)
+	return err
+}
+
+func (m *UserModel) SetSuspended(id int, suspended bool) error {
+	_, err := m.Get(id)
+	return err
+}
+
+func (m *UserModel) Search(query string, limit int) ([]*models.User, error) {
+	users := []*models.User{}
+
+	for _, u := range mockUsers {
+		if strings.Contains(u.Name, query) || strings.Contains(u.Username, query) || strings.Contains(u.Email, query) {
+			user, _ := m.Get(u.ID)
+			users = append(users, user)
+		}
+	}
+
+	return users, nil
 }
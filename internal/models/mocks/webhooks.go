@@ -0,0 +1,123 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/models"
+)
+
+var mockWebhook = &models.Webhook{
+	ID:      1,
+	UserID:  1,
+	URL:     "https://example.com/hook",
+	Secret:  "mocksecret",
+	Events:  []string{models.EventSnippetCreated, models.EventSnippetDeleted},
+	Created: time.Now(),
+}
+
+// keeps the enqueued deliveries in memory so tests can check which events were sent
+type WebhookModel struct {
+	mu      sync.Mutex
+	pending []*models.WebhookDelivery
+}
+
+func (m *WebhookModel) Insert(userID int, url, secret string, events []string) (int, error) {
+	return 2, nil
+}
+
+func (m *WebhookModel) Get(id, userID int) (*models.Webhook, error) {
+	if id == 1 && userID == 1 {
+		return mockWebhook, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *WebhookModel) ForUser(userID int) ([]*models.Webhook, error) {
+	if userID == 1 {
+		return []*models.Webhook{mockWebhook}, nil
+	}
+
+	return []*models.Webhook{}, nil
+}
+
+func (m *WebhookModel) Delete(id, userID int) error {
+	if id == 1 && userID == 1 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) Enqueue(userID int, event string, payload []byte) error {
+	if userID != 1 || !mockWebhook.Subscribed(event) {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending = append(m.pending, &models.WebhookDelivery{
+		ID:          len(m.pending) + 1,
+		WebhookID:   mockWebhook.ID,
+		Event:       event,
+		Payload:     payload,
+		Status:      models.DeliveryPending,
+		NextAttempt: time.Now(),
+		Created:     time.Now(),
+		URL:         mockWebhook.URL,
+		Secret:      mockWebhook.Secret,
+	})
+
+	return nil
+}
+
+func (m *WebhookModel) Deliveries(webhookID int) ([]*models.WebhookDelivery, error) {
+	if webhookID != 1 {
+		return []*models.WebhookDelivery{}, nil
+	}
+
+	return []*models.WebhookDelivery{
+		{
+			ID:           1,
+			WebhookID:    1,
+			Event:        models.EventSnippetCreated,
+			Status:       models.DeliveryDelivered,
+			Attempts:     1,
+			ResponseCode: 204,
+			Created:      time.Now(),
+		},
+	}, nil
+}
+
+func (m *WebhookModel) Due(limit int) ([]*models.WebhookDelivery, error) {
+	return []*models.WebhookDelivery{}, nil
+}
+
+func (m *WebhookModel) PendingForUser(userID int) ([]*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries := []*models.WebhookDelivery{}
+
+	for _, d := range m.pending {
+		if userID == mockWebhook.UserID && d.Status == models.DeliveryPending {
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (m *WebhookModel) MarkDelivered(id, responseCode int) error {
+	return nil
+}
+
+func (m *WebhookModel) MarkFailed(id, responseCode int, lastError string, nextAttempt time.Time, giveUp bool) error {
+	return nil
+}
+
+func (m *WebhookModel) Prune(before time.Time) error {
+	return nil
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashes passwords with the parameters encoded in the stored hash,
+// so hashes made with other parameters can still be checked
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	// reports whether the hash was created by this kind of hasher
+	Supports(hash []byte) bool
+	// fails with ErrInvalidCredentials if the password doesn't match the hash
+	Compare(hash []byte, password string) error
+	// reports whether the hash should be replaced by a new one from this hasher,
+	// because it was made with another algorithm or other parameters
+	NeedsRehash(hash []byte) bool
+}
+
+// used to hash new passwords unless the UserModel is given another hasher,
+// the parameters are the ones recommended by the argon2 package
+var DefaultPasswordHasher PasswordHasher = &Argon2idHasher{
+	Memory:      64 * 1024,
+	Iterations:  1,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// every algorithm a stored hash may use, the parameters are taken from the hash
+var passwordHashers = []PasswordHasher{&Argon2idHasher{}, &BcryptHasher{}}
+
+var errUnknownPasswordHash = errors.New("models: unknown password hash format")
+
+// checks the password against a hash of any of the supported algorithms
+func comparePassword(hash []byte, password string) error {
+	for _, h := range passwordHashers {
+		if h.Supports(hash) {
+			return h.Compare(hash, password)
+		}
+	}
+
+	return errUnknownPasswordHash
+}
+
+// hashes in the PHC string format, e.g. $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (h *Argon2idHasher) Hash(password string) ([]byte, error) {
+	salt := make([]byte, h.SaltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return []byte(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))), nil
+}
+
+func (h *Argon2idHasher) Supports(hash []byte) bool {
+	return bytes.HasPrefix(hash, []byte(argon2idPrefix))
+}
+
+func (h *Argon2idHasher) Compare(hash []byte, password string) error {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash []byte) bool {
+	params, salt, _, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.Memory || params.Iterations != h.Iterations ||
+		params.Parallelism != h.Parallelism || params.KeyLength != h.KeyLength ||
+		uint32(len(salt)) != h.SaltLength
+}
+
+// splits an encoded argon2id hash into its parameters, salt and key
+func decodeArgon2id(hash []byte) (*Argon2idHasher, []byte, []byte, error) {
+	var version int
+	var salt, key string
+	params := &Argon2idHasher{}
+
+	// fmt stops %s at spaces only, so the $ separators are turned into spaces first
+	encoded := bytes.ReplaceAll(bytes.TrimPrefix(hash, []byte(argon2idPrefix)), []byte("$"), []byte(" "))
+
+	_, err := fmt.Sscanf(string(encoded), "v=%d m=%d,t=%d,p=%d %s %s",
+		&version, &params.Memory, &params.Iterations, &params.Parallelism, &salt, &key)
+	if err != nil || version != argon2.Version {
+		return nil, nil, nil, errUnknownPasswordHash
+	}
+
+	saltBytes, err := base64.RawStdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, nil, nil, errUnknownPasswordHash
+	}
+
+	keyBytes, err := base64.RawStdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, nil, nil, errUnknownPasswordHash
+	}
+
+	params.SaltLength = uint32(len(saltBytes))
+	params.KeyLength = uint32(len(keyBytes))
+
+	return params, saltBytes, keyBytes, nil
+}
+
+// hashes in the modular crypt format of bcrypt, e.g. $2a$12$<salt and key>
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+}
+
+func (h *BcryptHasher) Supports(hash []byte) bool {
+	_, err := bcrypt.Cost(hash)
+	return err == nil
+}
+
+func (h *BcryptHasher) Compare(hash []byte, password string) error {
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidCredentials
+	}
+
+	return err
+}
+
+func (h *BcryptHasher) NeedsRehash(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost != h.Cost
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+// cheap parameters, the real ones would slow the tests down
+var testArgon2idHasher = &Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHashers(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+	}{
+		{"Argon2id", testArgon2idHasher},
+		{"Bcrypt", &BcryptHasher{Cost: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hasher.Hash("pa55word")
+			assert.NilError(t, err)
+
+			assert.Equal(t, tt.hasher.Supports(hash), true)
+			assert.Equal(t, tt.hasher.NeedsRehash(hash), false)
+			assert.NilError(t, comparePassword(hash, "pa55word"))
+			assert.Equal(t, comparePassword(hash, "wrong"), ErrInvalidCredentials)
+		})
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	bcryptHash, err := (&BcryptHasher{Cost: 4}).Hash("pa55word")
+	assert.NilError(t, err)
+
+	argon2idHash, err := testArgon2idHasher.Hash("pa55word")
+	assert.NilError(t, err)
+
+	stronger := *testArgon2idHasher
+	stronger.Iterations = 2
+
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   []byte
+		want   bool
+	}{
+		{"Same parameters", testArgon2idHasher, argon2idHash, false},
+		{"Other algorithm", testArgon2idHasher, bcryptHash, true},
+		{"Other parameters", &stronger, argon2idHash, true},
+		{"Other cost", &BcryptHasher{Cost: 5}, bcryptHash, true},
+		{"Garbage", testArgon2idHasher, []byte("$argon2id$nonsense"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.hasher.NeedsRehash(tt.hash), tt.want)
+		})
+	}
+}
+
+func TestComparePasswordUnknownHash(t *testing.T) {
+	assert.Equal(t, comparePassword([]byte("plaintext"), "plaintext"), errUnknownPasswordHash)
+}
+
+func TestCompareDummyPassword(t *testing.T) {
+	m := &UserModel{Hasher: &BcryptHasher{Cost: 4}}
+
+	assert.Equal(t, m.compareDummy("pa55word"), ErrInvalidCredentials)
+	assert.Equal(t, m.compareDummy(""), ErrInvalidCredentials)
+
+	// made by the configured hasher, so it costs as much as checking a real password
+	assert.Equal(t, m.Hasher.Supports(m.dummy), true)
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+type RememberTokenModelInterface interface {
+	New(userID int, ttl time.Duration) (string, error)
+	Rotate(token string, ttl time.Duration) (int, string, error)
+	Delete(token string) error
+	DeleteAllForUser(userID int, exceptToken string) error
+}
+
+// how long the validator a token was just rotated away from keeps working, so concurrent
+// requests sent with the old cookie aren't mistaken for a stolen token
+const rememberRotationGrace = time.Minute
+
+// long-lived "remember me" tokens in the form <selector>.<validator>, the selector finds
+// the row and only a sha256 hash of the validator is stored, which changes on every use
+type RememberTokenModel struct {
+	DB *sql.DB
+}
+
+// creates a token for the user that expires after ttl and returns it in plain text
+func (m *RememberTokenModel) New(userID int, ttl time.Duration) (string, error) {
+	selector, err := randomSelector()
+	if err != nil {
+		return "", err
+	}
+
+	validator, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO remember_tokens (selector, validator_hash, user_id, created, expiry)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	_, err = m.DB.Exec(query, selector, hashToken(validator), userID, time.Now().Add(ttl).UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return selector + "." + validator, nil
+}
+
+// checks the token and replaces its validator, returning the user and the new token,
+// the new token is empty if the request raced with another one that rotated it already.
+// fails with ErrInvalidToken if the token doesn't exist or expired, and with ErrTokenReused
+// if an old validator is presented, which means someone else has a copy of the token,
+// all tokens of the user are revoked then
+func (m *RememberTokenModel) Rotate(token string, ttl time.Duration) (int, string, error) {
+	selector, validator, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, "", ErrInvalidToken
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer tx.Rollback()
+
+	var id, userID int
+	var validatorHash string
+	var previousHash sql.NullString
+	var rotated sql.NullTime
+	var expiry time.Time
+
+	query := `SELECT id, user_id, validator_hash, previous_hash, rotated, expiry
+	FROM remember_tokens WHERE selector = ? FOR UPDATE`
+
+	err = tx.QueryRow(query, selector).Scan(&id, &userID, &validatorHash, &previousHash, &rotated, &expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", ErrInvalidToken
+		} else {
+			return 0, "", err
+		}
+	}
+
+	if time.Now().After(expiry) {
+		_, err = tx.Exec(`DELETE FROM remember_tokens WHERE id = ?`, id)
+		if err != nil {
+			return 0, "", err
+		}
+
+		return 0, "", errors.Join(ErrInvalidToken, tx.Commit())
+	}
+
+	hash := hashToken(validator)
+
+	switch {
+	case subtle.ConstantTimeCompare([]byte(hash), []byte(validatorHash)) == 1:
+		newValidator, err := randomToken()
+		if err != nil {
+			return 0, "", err
+		}
+
+		query = `UPDATE remember_tokens SET validator_hash = ?, previous_hash = ?, rotated = UTC_TIMESTAMP(), expiry = ?
+		WHERE id = ?`
+
+		_, err = tx.Exec(query, hashToken(newValidator), validatorHash, time.Now().Add(ttl).UTC(), id)
+		if err != nil {
+			return 0, "", err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return 0, "", err
+		}
+
+		return userID, selector + "." + newValidator, nil
+	case previousHash.Valid && subtle.ConstantTimeCompare([]byte(hash), []byte(previousHash.String)) == 1 &&
+		rotated.Valid && time.Since(rotated.Time) < rememberRotationGrace:
+		return userID, "", nil
+	default:
+		_, err = tx.Exec(`DELETE FROM remember_tokens WHERE user_id = ?`, userID)
+		if err != nil {
+			return 0, "", err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return 0, "", err
+		}
+
+		return userID, "", ErrTokenReused
+	}
+}
+
+// revokes a single token, e.g. on logout
+func (m *RememberTokenModel) Delete(token string) error {
+	selector, _, _ := strings.Cut(token, ".")
+
+	_, err := m.DB.Exec(`DELETE FROM remember_tokens WHERE selector = ?`, selector)
+	return err
+}
+
+// revokes all tokens of the user, except exceptToken if it isn't empty
+func (m *RememberTokenModel) DeleteAllForUser(userID int, exceptToken string) error {
+	selector, _, _ := strings.Cut(exceptToken, ".")
+
+	query := `DELETE FROM remember_tokens WHERE user_id = ? AND selector != ?`
+
+	_, err := m.DB.Exec(query, userID, selector)
+	return err
+}
+
+// returns a hex encoded string of 12 random bytes, the lookup part of a remember token
+func randomSelector() (string, error) {
+	b := make([]byte, 12)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
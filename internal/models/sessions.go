@@ -0,0 +1,145 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SessionModelInterface interface {
+	Insert(userID int, ip, userAgent string) (string, error)
+	Get(token string) (*Session, error)
+	Touch(id int, ip string) error
+	ForUser(userID int) ([]*Session, error)
+	Delete(id, userID int) error
+	DeleteAllForUser(userID, exceptID int) error
+}
+
+// Represents a logged in session, the scs session data only holds its token
+type Session struct {
+	ID        int
+	UserID    int
+	Created   time.Time
+	LastSeen  time.Time
+	IP        string
+	UserAgent string
+}
+
+// keeps track of the logged in sessions of users so they can be listed and revoked,
+// Lifetime must match the lifetime of the session manager
+type SessionModel struct {
+	DB       *sql.DB
+	Lifetime time.Duration
+}
+
+// records a new session for the user and returns its token, only a hash of it is stored
+func (m *SessionModel) Insert(userID int, ip, userAgent string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	// user agents can be arbitrarily long
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	// housekeeping, sessions older than the lifetime expired in the session store as well
+	_, err = m.DB.Exec(`DELETE FROM user_sessions WHERE user_id = ? AND created < ?`, userID, m.expiredBefore())
+	if err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO user_sessions (user_id, token_hash, created, last_seen, ip, user_agent)
+	VALUES(?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?, ?)`
+
+	_, err = m.DB.Exec(query, userID, hashToken(token), ip, userAgent)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// returns the session with the token, ErrNoRecord if it was revoked or expired
+func (m *SessionModel) Get(token string) (*Session, error) {
+	query := `SELECT id, user_id, created, last_seen, ip, user_agent FROM user_sessions
+	WHERE token_hash = ? AND created > ?`
+
+	s := &Session{}
+
+	err := m.DB.QueryRow(query, hashToken(token), m.expiredBefore()).Scan(&s.ID, &s.UserID, &s.Created, &s.LastSeen, &s.IP, &s.UserAgent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// updates the last seen time and ip address of the session
+func (m *SessionModel) Touch(id int, ip string) error {
+	query := `UPDATE user_sessions SET last_seen = UTC_TIMESTAMP(), ip = ? WHERE id = ?`
+
+	_, err := m.DB.Exec(query, ip, id)
+	return err
+}
+
+// returns the sessions of the user that haven't expired yet, most recently used first
+func (m *SessionModel) ForUser(userID int) ([]*Session, error) {
+	query := `SELECT id, user_id, created, last_seen, ip, user_agent FROM user_sessions
+	WHERE user_id = ? AND created > ? ORDER BY last_seen DESC`
+
+	rows, err := m.DB.Query(query, userID, m.expiredBefore())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		s := &Session{}
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Created, &s.LastSeen, &s.IP, &s.UserAgent)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// revokes a session of the user
+func (m *SessionModel) Delete(id, userID int) error {
+	query := `DELETE FROM user_sessions WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// revokes all sessions of the user except the one with exceptID, pass 0 to revoke all of them
+func (m *SessionModel) DeleteAllForUser(userID, exceptID int) error {
+	query := `DELETE FROM user_sessions WHERE user_id = ? AND id <> ?`
+
+	_, err := m.DB.Exec(query, userID, exceptID)
+	return err
+}
+
+func (m *SessionModel) expiredBefore() time.Time {
+	return time.Now().UTC().Add(-m.Lifetime)
+}
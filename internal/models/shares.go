@@ -0,0 +1,111 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// levels of access to a snippet from least to most privileged, readers can view it,
+// writers can also edit it and the owner can delete and share it
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+	AccessOwner = "owner"
+)
+
+// ShareAccesses lists the access levels that can be granted to other users
+var ShareAccesses = []string{AccessRead, AccessWrite}
+
+// reports whether the access level grants at least the privileges of required
+func AccessAtLeast(access, required string) bool {
+	return rankAtLeast([]string{AccessRead, AccessWrite, AccessOwner}, access, required)
+}
+
+type ShareModelInterface interface {
+	Upsert(snippetID, userID int, access string) error
+	Access(snippetID, userID int) (string, error)
+	ForSnippet(snippetID int) ([]*Share, error)
+	Delete(snippetID, userID int) error
+}
+
+// Represents a user a snippet was shared with
+type Share struct {
+	SnippetID int
+	UserID    int
+	Name      string
+	Username  string
+	Email     string
+	Access    string
+	Created   time.Time
+}
+
+type ShareModel struct {
+	DB *sql.DB
+}
+
+// shares the snippet with the user, changing the access level if it already was
+func (m *ShareModel) Upsert(snippetID, userID int, access string) error {
+	query := `INSERT INTO snippet_shares (snippet_id, user_id, access, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP()) ON DUPLICATE KEY UPDATE access = VALUES(access)`
+
+	_, err := m.DB.Exec(query, snippetID, userID, access)
+	return err
+}
+
+// returns the access level granted to the user, empty if the snippet wasn't shared with them
+func (m *ShareModel) Access(snippetID, userID int) (string, error) {
+	var access string
+
+	query := `SELECT access FROM snippet_shares WHERE snippet_id = ? AND user_id = ?`
+
+	err := m.DB.QueryRow(query, snippetID, userID).Scan(&access)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	return access, nil
+}
+
+// returns the users the snippet was shared with, sorted by name
+func (m *ShareModel) ForSnippet(snippetID int) ([]*Share, error) {
+	query := `SELECT ss.snippet_id, u.id, u.name, COALESCE(u.username, ''), u.email, ss.access, ss.created
+	FROM snippet_shares ss INNER JOIN users u ON u.id = ss.user_id
+	WHERE ss.snippet_id = ? ORDER BY u.name`
+
+	rows, err := m.DB.Query(query, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	shares := []*Share{}
+
+	for rows.Next() {
+		s := &Share{}
+
+		err = rows.Scan(&s.SnippetID, &s.UserID, &s.Name, &s.Username, &s.Email, &s.Access, &s.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		shares = append(shares, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// revokes the access granted to the user
+func (m *ShareModel) Delete(snippetID, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM snippet_shares WHERE snippet_id = ? AND user_id = ?`, snippetID, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
@@ -7,18 +7,44 @@ import (
 )
 
 type SnippetModelInterface interface {
-	Insert(title, content string, expires int) (int, error)
+	Insert(userID int, title, content, language string, expires int, private bool) (int, error)
+	InsertForTeam(userID, teamID int, title, content, language string, expires int, private bool) (int, error)
 	Get(id int) (*Snippet, error)
 	Latest() ([]*Snippet, error)
+	ByUser(userID, limit int) ([]*Snippet, error)
+	OwnedBy(userID, limit int) ([]*Snippet, error)
+	ByTeam(teamID, limit int) ([]*Snippet, error)
+	SharedWith(userID, limit int) ([]*Snippet, error)
+	StarredBy(userID, limit int) ([]*Snippet, error)
+	Update(id, userID int, title, content string) error
+	UpdateForTeam(id, teamID int, title, content string) error
+	Delete(id, userID int) error
+	DeleteForTeam(id, teamID int) error
+	DeleteAny(id int) error
+	Expired() ([]*Snippet, error)
 }
 
 // Represents a snippet in the database
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID             int
+	UserID         int    // 0 for snippets created before ownership was recorded
+	Author         string // name of the owner, empty if there is none
+	AuthorUsername string // empty if there is no owner or they never picked a username
+	TeamID         int    // 0 unless the snippet is owned by a team
+	Team           string // name of the owning team
+	TeamSlug       string
+	Private        bool // only visible to the author, team members and users it was shared with
+	Title          string
+	Content        string
+	Language       string // optional language hint, e.g. "go" or "yaml"
+	Created        time.Time
+	Expires        time.Time
+	Stars          int // number of users who starred the snippet
+}
+
+// reports whether the snippet has expired, only lists that keep expired snippets return them
+func (s *Snippet) Expired() bool {
+	return !s.Expires.After(time.Now())
 }
 
 // wrapper for sql.DB connection pool
@@ -26,11 +52,11 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
-func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
-	query := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+func (m *SnippetModel) Insert(userID int, title, content, language string, expires int, private bool) (int, error) {
+	query := `INSERT INTO snippets (user_id, private, title, content, language, created, expires)
+	VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
 
-	result, err := m.DB.Exec(query, title, content, expires)
+	result, err := m.DB.Exec(query, userID, private, title, content, language, expires)
 	if err != nil {
 		return 0, err
 	}
@@ -44,14 +70,36 @@ func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
 	return int(id), nil
 }
 
+// creates a snippet owned by the team, the user is recorded as its author
+func (m *SnippetModel) InsertForTeam(userID, teamID int, title, content, language string, expires int, private bool) (int, error) {
+	query := `INSERT INTO snippets (user_id, team_id, private, title, content, language, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+
+	result, err := m.DB.Exec(query, userID, teamID, private, title, content, language, expires)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	query := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	query := `SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, ''), COALESCE(u.username, ''),
+	COALESCE(s.team_id, 0), COALESCE(t.name, ''), COALESCE(t.slug, ''), s.private, s.title, s.content, s.language, s.created, s.expires,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id)
+	FROM snippets s LEFT JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id
+	WHERE s.expires > UTC_TIMESTAMP() AND s.id = ?`
 
 	s := &Snippet{}
 
 	// query the database for a snippet with the given ID, then copy the values into the Snippet struct
-	err := m.DB.QueryRow(query, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := m.DB.QueryRow(query, id).Scan(&s.ID, &s.UserID, &s.Author, &s.AuthorUsername,
+		&s.TeamID, &s.Team, &s.TeamSlug, &s.Private, &s.Title, &s.Content, &s.Language, &s.Created, &s.Expires, &s.Stars)
 	if err != nil {
 		// check if no matching record is found
 		if errors.Is(err, sql.ErrNoRows) {
@@ -64,10 +112,13 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return s, nil
 }
 
-// returns the 10 most recently created snippets
+// returns the 10 most recently created snippets, leaving out private ones
 func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	query := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
+	query := `SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, ''), COALESCE(u.username, ''),
+	COALESCE(s.team_id, 0), COALESCE(t.name, ''), COALESCE(t.slug, ''), s.private, s.title, s.content, s.language, s.created, s.expires,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id)
+	FROM snippets s LEFT JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id
+	WHERE s.expires > UTC_TIMESTAMP() AND s.private = FALSE ORDER BY s.created DESC LIMIT 10`
 
 	rows, err := m.DB.Query(query)
 	if err != nil {
@@ -77,13 +128,242 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	// defer the closing of the rows until the function returns
 	defer rows.Close()
 
+	return scanSnippets(rows)
+}
+
+// returns the most recently created unexpired snippets of a user, leaving out private ones
+func (m *SnippetModel) ByUser(userID, limit int) ([]*Snippet, error) {
+	query := `SELECT s.id, s.user_id, u.name, COALESCE(u.username, ''),
+	COALESCE(s.team_id, 0), COALESCE(t.name, ''), COALESCE(t.slug, ''), s.private, s.title, s.content, s.language, s.created, s.expires,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id)
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id
+	WHERE s.expires > UTC_TIMESTAMP() AND s.user_id = ? AND s.private = FALSE ORDER BY s.created DESC LIMIT ?`
+
+	rows, err := m.DB.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanSnippets(rows)
+}
+
+// returns the most recently created unexpired snippets of a user including private ones,
+// only meant for listings the user sees themselves
+func (m *SnippetModel) OwnedBy(userID, limit int) ([]*Snippet, error) {
+	query := `SELECT s.id, s.user_id, u.name, COALESCE(u.username, ''),
+	COALESCE(s.team_id, 0), COALESCE(t.name, ''), COALESCE(t.slug, ''), s.private, s.title, s.content, s.language, s.created, s.expires,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id)
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id
+	WHERE s.expires > UTC_TIMESTAMP() AND s.user_id = ? ORDER BY s.created DESC LIMIT ?`
+
+	rows, err := m.DB.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanSnippets(rows)
+}
+
+// returns the most recently created unexpired snippets of a team, including private ones
+func (m *SnippetModel) ByTeam(teamID, limit int) ([]*Snippet, error) {
+	query := `SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, ''), COALESCE(u.username, ''),
+	COALESCE(s.team_id, 0), COALESCE(t.name, ''), COALESCE(t.slug, ''), s.private, s.title, s.content, s.language, s.created, s.expires,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id)
+	FROM snippets s LEFT JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id
+	WHERE s.expires > UTC_TIMESTAMP() AND s.team_id = ? ORDER BY s.created DESC LIMIT ?`
+
+	rows, err := m.DB.Query(query, teamID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanSnippets(rows)
+}
+
+// returns the most recently created unexpired snippets other users shared with the user
+func (m *SnippetModel) SharedWith(userID, limit int) ([]*Snippet, error) {
+	query := `SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, ''), COALESCE(u.username, ''),
+	COALESCE(s.team_id, 0), COALESCE(t.name, ''), COALESCE(t.slug, ''), s.private, s.title, s.content, s.language, s.created, s.expires,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id)
+	FROM snippet_shares ss INNER JOIN snippets s ON s.id = ss.snippet_id
+	LEFT JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id
+	WHERE s.expires > UTC_TIMESTAMP() AND ss.user_id = ? ORDER BY s.created DESC LIMIT ?`
+
+	rows, err := m.DB.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanSnippets(rows)
+}
+
+// returns the snippets the user starred, most recently starred first, including expired ones
+func (m *SnippetModel) StarredBy(userID, limit int) ([]*Snippet, error) {
+	query := `SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, ''), COALESCE(u.username, ''),
+	COALESCE(s.team_id, 0), COALESCE(t.name, ''), COALESCE(t.slug, ''), s.private, s.title, s.content, s.language, s.created, s.expires,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id)
+	FROM stars us INNER JOIN snippets s ON s.id = us.snippet_id
+	LEFT JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id
+	WHERE us.user_id = ? ORDER BY us.created DESC LIMIT ?`
+
+	rows, err := m.DB.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanSnippets(rows)
+}
+
+// updates the title and content of a snippet written by the given user
+func (m *SnippetModel) Update(id, userID int, title, content string) error {
+	return m.update("user_id", id, userID, title, content)
+}
+
+// updates the title and content of a snippet owned by the team
+func (m *SnippetModel) UpdateForTeam(id, teamID int, title, content string) error {
+	return m.update("team_id", id, teamID, title, content)
+}
+
+// updates a snippet whose owner column, user_id or team_id, matches ownerID
+func (m *SnippetModel) update(ownerColumn string, id, ownerID int, title, content string) error {
+	query := `UPDATE snippets SET title = ?, content = ?
+	WHERE id = ? AND ` + ownerColumn + ` = ? AND expires > UTC_TIMESTAMP()`
+
+	result, err := m.DB.Exec(query, title, content, id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	// mysql reports zero affected rows when the values didn't change,
+	// so check whether the snippet actually exists for this owner
+	var exists bool
+
+	query = `SELECT EXISTS(SELECT true FROM snippets
+	WHERE id = ? AND ` + ownerColumn + ` = ? AND expires > UTC_TIMESTAMP())`
+
+	err = m.DB.QueryRow(query, id, ownerID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// deletes a snippet owned by the given user along with its shares, links, stars and comments
+func (m *SnippetModel) Delete(id, userID int) error {
+	query := `DELETE s, ss, sl, st, c FROM snippets s LEFT JOIN snippet_shares ss ON ss.snippet_id = s.id
+	LEFT JOIN share_links sl ON sl.snippet_id = s.id LEFT JOIN stars st ON st.snippet_id = s.id
+	LEFT JOIN comments c ON c.snippet_id = s.id
+	WHERE s.id = ? AND s.user_id = ?`
+
+	result, err := m.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// deletes a snippet owned by the team along with its shares, links, stars and comments
+func (m *SnippetModel) DeleteForTeam(id, teamID int) error {
+	query := `DELETE s, ss, sl, st, c FROM snippets s LEFT JOIN snippet_shares ss ON ss.snippet_id = s.id
+	LEFT JOIN share_links sl ON sl.snippet_id = s.id LEFT JOIN stars st ON st.snippet_id = s.id
+	LEFT JOIN comments c ON c.snippet_id = s.id
+	WHERE s.id = ? AND s.team_id = ?`
+
+	result, err := m.DB.Exec(query, id, teamID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// deletes a snippet regardless of its owner, for moderation
+func (m *SnippetModel) DeleteAny(id int) error {
+	query := `DELETE s, ss, sl, st, c FROM snippets s LEFT JOIN snippet_shares ss ON ss.snippet_id = s.id
+	LEFT JOIN share_links sl ON sl.snippet_id = s.id LEFT JOIN stars st ON st.snippet_id = s.id
+	LEFT JOIN comments c ON c.snippet_id = s.id
+	WHERE s.id = ?`
+
+	result, err := m.DB.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// returns the owned snippets that expired since the last call and flags them,
+// so each expiry is only ever reported once
+func (m *SnippetModel) Expired() ([]*Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	query := `SELECT s.id, s.user_id, u.name, COALESCE(u.username, ''),
+	COALESCE(s.team_id, 0), COALESCE(t.name, ''), COALESCE(t.slug, ''), s.private, s.title, s.content, s.language, s.created, s.expires,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id)
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id LEFT JOIN teams t ON t.id = s.team_id
+	WHERE s.expires <= UTC_TIMESTAMP() AND s.expired_notified = FALSE
+	FOR UPDATE`
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range snippets {
+		_, err = tx.Exec(`UPDATE snippets SET expired_notified = TRUE WHERE id = ?`, s.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return snippets, tx.Commit()
+}
+
+// copies the values of each row into a Snippet struct, rows must select
+// id, user_id, author name, author username, team_id, team name, team slug, private,
+// title, content, language, created, expires and the star count in that order
+func scanSnippets(rows *sql.Rows) ([]*Snippet, error) {
 	snippets := []*Snippet{}
 
 	// iterate through the rows with rows.Next() and copy the values from each row into a Snippet struct
 	for rows.Next() {
 		s := &Snippet{}
 
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := rows.Scan(&s.ID, &s.UserID, &s.Author, &s.AuthorUsername,
+			&s.TeamID, &s.Team, &s.TeamSlug, &s.Private, &s.Title, &s.Content, &s.Language, &s.Created, &s.Expires, &s.Stars)
 		if err != nil {
 			return nil, err
 		}
@@ -93,9 +373,23 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	}
 
 	// check for errors encountered during iteration
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
 	return snippets, nil
 }
+
+// returns ErrNoRecord if an update or delete statement didn't match any row
+func checkRowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
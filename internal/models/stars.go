@@ -0,0 +1,39 @@
+package models
+
+import (
+	"database/sql"
+)
+
+type StarModelInterface interface {
+	Star(userID, snippetID int) error
+	Unstar(userID, snippetID int) error
+	Starred(userID, snippetID int) (bool, error)
+}
+
+type StarModel struct {
+	DB *sql.DB
+}
+
+// stars the snippet for the user, starring it again changes nothing
+func (m *StarModel) Star(userID, snippetID int) error {
+	query := `INSERT IGNORE INTO stars (user_id, snippet_id, created) VALUES(?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(query, userID, snippetID)
+	return err
+}
+
+// removes the star of the user, if there is one
+func (m *StarModel) Unstar(userID, snippetID int) error {
+	_, err := m.DB.Exec(`DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`, userID, snippetID)
+	return err
+}
+
+// reports whether the user starred the snippet
+func (m *StarModel) Starred(userID, snippetID int) (bool, error) {
+	var exists bool
+
+	query := `SELECT EXISTS(SELECT true FROM stars WHERE user_id = ? AND snippet_id = ?)`
+
+	err := m.DB.QueryRow(query, userID, snippetID).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"database/sql"
+)
+
+type StatsModelInterface interface {
+	Get() (*Stats, error)
+}
+
+// Represents an overview of the instance for the admin panel
+type Stats struct {
+	Users           int
+	VerifiedUsers   int
+	SuspendedUsers  int
+	Snippets        int // unexpired snippets
+	ExpiredSnippets int // expired snippets that haven't been cleaned up
+	Sessions        int
+	APITokens       int
+	Webhooks        int
+}
+
+type StatsModel struct {
+	DB *sql.DB
+}
+
+func (m *StatsModel) Get() (*Stats, error) {
+	query := `SELECT
+	(SELECT COUNT(*) FROM users),
+	(SELECT COUNT(*) FROM users WHERE email_verified = TRUE),
+	(SELECT COUNT(*) FROM users WHERE suspended = TRUE),
+	(SELECT COUNT(*) FROM snippets WHERE expires > UTC_TIMESTAMP()),
+	(SELECT COUNT(*) FROM snippets WHERE expires <= UTC_TIMESTAMP()),
+	(SELECT COUNT(*) FROM user_sessions),
+	(SELECT COUNT(*) FROM api_tokens),
+	(SELECT COUNT(*) FROM webhooks)`
+
+	s := &Stats{}
+
+	err := m.DB.QueryRow(query).Scan(&s.Users, &s.VerifiedUsers, &s.SuspendedUsers, &s.Snippets,
+		&s.ExpiredSnippets, &s.Sessions, &s.APITokens, &s.Webhooks)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,458 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// team roles from least to most privileged, members can see and create team snippets,
+// maintainers can also invite and remove members, owners manage roles and the team itself
+const (
+	TeamRoleMember     = "member"
+	TeamRoleMaintainer = "maintainer"
+	TeamRoleOwner      = "owner"
+)
+
+// TeamRoles lists the valid team roles in order of privilege
+var TeamRoles = []string{TeamRoleMember, TeamRoleMaintainer, TeamRoleOwner}
+
+// reports whether the team role grants at least the privileges of required
+func TeamRoleAtLeast(role, required string) bool {
+	return rankAtLeast(TeamRoles, role, required)
+}
+
+type TeamModelInterface interface {
+	Insert(name, slug string, ownerID int) (int, error)
+	GetBySlug(slug string) (*Team, error)
+	ForUser(userID int) ([]*Team, error)
+	Role(teamID, userID int) (string, error)
+	Members(teamID int) ([]*TeamMember, error)
+	SetRole(teamID, userID int, role string) error
+	RemoveMember(teamID, userID int) error
+	Delete(id int) error
+	Invite(teamID, invitedBy int, email, role string, ttl time.Duration) (string, error)
+	Invitations(teamID int) ([]*TeamInvitation, error)
+	GetInvitation(token string) (*TeamInvitation, error)
+	AcceptInvitation(token string, userID int) (int, error)
+	DeleteInvitation(id, teamID int) error
+}
+
+// Represents a team that can own snippets
+type Team struct {
+	ID      int
+	Name    string
+	Slug    string // unique identifier used in urls
+	Created time.Time
+	Role    string // role of the user the team was listed for, empty otherwise
+}
+
+// Represents a user's membership in a team
+type TeamMember struct {
+	UserID   int
+	Name     string
+	Username string
+	Email    string
+	Role     string
+	Joined   time.Time
+}
+
+// Represents a pending invitation to join a team, sent by email
+type TeamInvitation struct {
+	ID        int
+	TeamID    int
+	TeamName  string
+	TeamSlug  string
+	Email     string
+	Role      string
+	InvitedBy int
+	Created   time.Time
+	Expiry    time.Time
+}
+
+type TeamModel struct {
+	DB *sql.DB
+}
+
+// creates the team with the given user as its owner
+func (m *TeamModel) Insert(name, slug string, ownerID int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO teams (name, slug, created) VALUES(?, ?, UTC_TIMESTAMP())`, name, slug)
+	if err != nil {
+		if isDuplicateKey(err, "teams_uc_slug") {
+			return 0, ErrDuplicateSlug
+		}
+
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO team_members (team_id, user_id, role, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = tx.Exec(query, id, ownerID, TeamRoleOwner)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+func (m *TeamModel) GetBySlug(slug string) (*Team, error) {
+	t := &Team{}
+
+	query := `SELECT id, name, slug, created FROM teams WHERE slug = ?`
+
+	err := m.DB.QueryRow(query, slug).Scan(&t.ID, &t.Name, &t.Slug, &t.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// returns the teams the user is a member of along with their role, sorted by name
+func (m *TeamModel) ForUser(userID int) ([]*Team, error) {
+	query := `SELECT t.id, t.name, t.slug, t.created, tm.role
+	FROM teams t INNER JOIN team_members tm ON tm.team_id = t.id
+	WHERE tm.user_id = ? ORDER BY t.name`
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	teams := []*Team{}
+
+	for rows.Next() {
+		t := &Team{}
+
+		err = rows.Scan(&t.ID, &t.Name, &t.Slug, &t.Created, &t.Role)
+		if err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// returns the role of the user in the team, empty if they aren't a member
+func (m *TeamModel) Role(teamID, userID int) (string, error) {
+	var role string
+
+	query := `SELECT role FROM team_members WHERE team_id = ? AND user_id = ?`
+
+	err := m.DB.QueryRow(query, teamID, userID).Scan(&role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	return role, nil
+}
+
+// returns the members of the team, owners first
+func (m *TeamModel) Members(teamID int) ([]*TeamMember, error) {
+	query := `SELECT u.id, u.name, COALESCE(u.username, ''), u.email, tm.role, tm.created
+	FROM team_members tm INNER JOIN users u ON u.id = tm.user_id
+	WHERE tm.team_id = ? ORDER BY FIELD(tm.role, 'owner', 'maintainer', 'member'), u.name`
+
+	rows, err := m.DB.Query(query, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	members := []*TeamMember{}
+
+	for rows.Next() {
+		tm := &TeamMember{}
+
+		err = rows.Scan(&tm.UserID, &tm.Name, &tm.Username, &tm.Email, &tm.Role, &tm.Joined)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, tm)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// changes the role of a member, fails with ErrLastOwner if that would leave the team without an owner
+func (m *TeamModel) SetRole(teamID, userID int, role string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = checkLastOwner(tx, teamID, userID, role)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE team_members SET role = ? WHERE team_id = ? AND user_id = ?`, role, teamID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// removes a member from the team, fails with ErrLastOwner if they are its only owner,
+// their team snippets stay with the team
+func (m *TeamModel) RemoveMember(teamID, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = checkLastOwner(tx, teamID, userID, "")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// locks the memberships of the team and makes sure the member exists and that giving them
+// newRole, or removing them if it's empty, leaves at least one owner
+func checkLastOwner(tx *sql.Tx, teamID, userID int, newRole string) error {
+	rows, err := tx.Query(`SELECT user_id, role FROM team_members WHERE team_id = ? FOR UPDATE`, teamID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	var owners int
+	var role string
+
+	for rows.Next() {
+		var id int
+		var r string
+
+		err = rows.Scan(&id, &r)
+		if err != nil {
+			return err
+		}
+
+		if r == TeamRoleOwner {
+			owners++
+		}
+
+		if id == userID {
+			role = r
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if role == "" {
+		return ErrNoRecord
+	}
+
+	if role == TeamRoleOwner && newRole != TeamRoleOwner && owners == 1 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+// deletes the team along with its snippets, memberships and invitations
+func (m *TeamModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	queries := []string{
+		`DELETE FROM snippet_shares WHERE snippet_id IN (SELECT id FROM snippets WHERE team_id = ?)`,
+		`DELETE FROM share_links WHERE snippet_id IN (SELECT id FROM snippets WHERE team_id = ?)`,
+		`DELETE FROM stars WHERE snippet_id IN (SELECT id FROM snippets WHERE team_id = ?)`,
+		`DELETE FROM comments WHERE snippet_id IN (SELECT id FROM snippets WHERE team_id = ?)`,
+		`DELETE FROM snippets WHERE team_id = ?`,
+		`DELETE FROM team_invitations WHERE team_id = ?`,
+		`DELETE FROM team_members WHERE team_id = ?`,
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(query, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM teams WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// creates an invitation for the email address that expires after ttl and returns its token in plain text
+func (m *TeamModel) Invite(teamID, invitedBy int, email, role string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO team_invitations (team_id, email, role, token_hash, invited_by, created, expiry)
+	VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	_, err = m.DB.Exec(query, teamID, email, role, hashToken(token), invitedBy, time.Now().Add(ttl).UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// returns the unexpired invitations of the team, newest first
+func (m *TeamModel) Invitations(teamID int) ([]*TeamInvitation, error) {
+	query := `SELECT i.id, i.team_id, t.name, t.slug, i.email, i.role, i.invited_by, i.created, i.expiry
+	FROM team_invitations i INNER JOIN teams t ON t.id = i.team_id
+	WHERE i.team_id = ? AND i.expiry > UTC_TIMESTAMP() ORDER BY i.id DESC`
+
+	rows, err := m.DB.Query(query, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invitations := []*TeamInvitation{}
+
+	for rows.Next() {
+		i := &TeamInvitation{}
+
+		err = rows.Scan(&i.ID, &i.TeamID, &i.TeamName, &i.TeamSlug, &i.Email, &i.Role, &i.InvitedBy, &i.Created, &i.Expiry)
+		if err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// returns the invitation of the token, fails with ErrInvalidToken if it doesn't exist or expired
+func (m *TeamModel) GetInvitation(token string) (*TeamInvitation, error) {
+	i := &TeamInvitation{}
+
+	query := `SELECT i.id, i.team_id, t.name, t.slug, i.email, i.role, i.invited_by, i.created, i.expiry
+	FROM team_invitations i INNER JOIN teams t ON t.id = i.team_id
+	WHERE i.token_hash = ? AND i.expiry > UTC_TIMESTAMP()`
+
+	err := m.DB.QueryRow(query, hashToken(token)).Scan(&i.ID, &i.TeamID, &i.TeamName, &i.TeamSlug, &i.Email, &i.Role, &i.InvitedBy, &i.Created, &i.Expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		} else {
+			return nil, err
+		}
+	}
+
+	return i, nil
+}
+
+// adds the user to the team with the role of the invitation and deletes it, members keep
+// their current role, returns the team id or ErrInvalidToken if the token doesn't exist or expired
+func (m *TeamModel) AcceptInvitation(token string, userID int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var id, teamID int
+	var role string
+
+	query := `SELECT id, team_id, role FROM team_invitations
+	WHERE token_hash = ? AND expiry > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(query, hashToken(token)).Scan(&id, &teamID, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidToken
+		} else {
+			return 0, err
+		}
+	}
+
+	query = `INSERT IGNORE INTO team_members (team_id, user_id, role, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = tx.Exec(query, teamID, userID, role)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM team_invitations WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return teamID, tx.Commit()
+}
+
+// revokes a pending invitation of the team
+func (m *TeamModel) DeleteInvitation(id, teamID int) error {
+	result, err := m.DB.Exec(`DELETE FROM team_invitations WHERE id = ? AND team_id = ?`, id, teamID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
@@ -1,26 +1,244 @@
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER,
+    team_id INTEGER,
+    private BOOLEAN NOT NULL DEFAULT FALSE,
     title VARCHAR(100) NOT NULL,
     content TEXT NOT NULL,
+    language VARCHAR(30) NOT NULL DEFAULT '',
     created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
+    expires DATETIME NOT NULL,
+    expired_notified BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+CREATE INDEX idx_snippets_team_id ON snippets(team_id);
 
 CREATE TABLE users (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
     name VARCHAR(255) NOT NULL,
+    username VARCHAR(30),
     email VARCHAR(255) NOT NULL,
-    hashed_password CHAR(60) NOT NULL,
-    created DATETIME NOT NULL
+    hashed_password VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    feed_token CHAR(64),
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_secret VARBINARY(255),
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT NOT NULL DEFAULT 0,
+    deletion_scheduled DATETIME,
+    deletion_mode VARCHAR(10),
+    pending_email VARCHAR(255),
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    suspended BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+ALTER TABLE users ADD CONSTRAINT users_uc_username UNIQUE (username);
+ALTER TABLE users ADD CONSTRAINT users_uc_feed_token UNIQUE (feed_token);
 
-INSERT INTO users (name, email, hashed_password, created) VALUES (
+INSERT INTO users (name, username, email, hashed_password, created, email_verified) VALUES (
     'Mocky McMockface',
+    'mocky',
     'mocky@example.com',
     '$2a$12$4JQwyw09D/U1GAwbdeo4iOYg2cLbq86Tz1PB.n1AS1Oo6Umb.H4nS',
-    '2023-01-01 11:00:00'
+    '2023-01-01 11:00:00',
+    TRUE
+);
+
+CREATE TABLE webhooks (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret CHAR(64) NOT NULL,
+    events VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_webhooks_user_id ON webhooks(user_id);
+
+CREATE TABLE webhook_deliveries (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    next_attempt DATETIME NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt);
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+CREATE INDEX idx_webhook_deliveries_created ON webhook_deliveries(created);
+
+CREATE TABLE api_tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME
+);
+
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash);
+CREATE INDEX idx_api_tokens_user_id ON api_tokens(user_id);
+
+CREATE TABLE tokens (
+    hash CHAR(64) NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    scope VARCHAR(30) NOT NULL,
+    expiry DATETIME NOT NULL
+);
+
+CREATE INDEX idx_tokens_user_id ON tokens(user_id);
+
+CREATE TABLE recovery_codes (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash CHAR(64) NOT NULL
+);
+
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+CREATE TABLE user_identities (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE user_identities ADD CONSTRAINT user_identities_uc_issuer_subject UNIQUE (issuer, subject);
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+CREATE TABLE user_sessions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL
+);
+
+ALTER TABLE user_sessions ADD CONSTRAINT user_sessions_uc_token_hash UNIQUE (token_hash);
+CREATE INDEX idx_user_sessions_user_id ON user_sessions(user_id);
+
+CREATE TABLE login_lockouts (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    created DATETIME NOT NULL,
+    until DATETIME NOT NULL
+);
+
+CREATE INDEX idx_login_lockouts_user_id ON login_lockouts(user_id);
+
+CREATE TABLE remember_tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    selector CHAR(24) NOT NULL,
+    validator_hash CHAR(64) NOT NULL,
+    previous_hash CHAR(64),
+    rotated DATETIME,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expiry DATETIME NOT NULL
 );
+
+ALTER TABLE remember_tokens ADD CONSTRAINT remember_tokens_uc_selector UNIQUE (selector);
+CREATE INDEX idx_remember_tokens_user_id ON remember_tokens(user_id);
+
+CREATE TABLE audit_events (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER,
+    action VARCHAR(50) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    detail VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_events_user_id ON audit_events(user_id);
+CREATE INDEX idx_audit_events_created ON audit_events(created);
+
+CREATE TABLE teams (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(30) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE teams ADD CONSTRAINT teams_uc_slug UNIQUE (slug);
+
+CREATE TABLE team_members (
+    team_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX idx_team_members_user_id ON team_members(user_id);
+
+CREATE TABLE team_invitations (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    team_id INTEGER NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    invited_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expiry DATETIME NOT NULL
+);
+
+ALTER TABLE team_invitations ADD CONSTRAINT team_invitations_uc_token_hash UNIQUE (token_hash);
+CREATE INDEX idx_team_invitations_team_id ON team_invitations(team_id);
+
+CREATE TABLE snippet_shares (
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    access VARCHAR(10) NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, user_id)
+);
+
+CREATE INDEX idx_snippet_shares_user_id ON snippet_shares(user_id);
+
+CREATE TABLE share_links (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expiry DATETIME NOT NULL
+);
+
+CREATE INDEX idx_share_links_snippet_id ON share_links(snippet_id);
+
+CREATE TABLE stars (
+    user_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_stars_snippet_id ON stars(snippet_id);
+
+CREATE TABLE comments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER,
+    parent_id INTEGER,
+    line INTEGER NOT NULL DEFAULT 0,
+    body TEXT NOT NULL,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    created DATETIME NOT NULL,
+    updated DATETIME NOT NULL
+);
+
+CREATE INDEX idx_comments_snippet_id ON comments(snippet_id);
+CREATE INDEX idx_comments_user_id ON comments(user_id);
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
@@ -1,3 +1,37 @@
+DROP TABLE comments;
+
+DROP TABLE stars;
+
+DROP TABLE share_links;
+
+DROP TABLE snippet_shares;
+
+DROP TABLE team_invitations;
+
+DROP TABLE team_members;
+
+DROP TABLE teams;
+
+DROP TABLE audit_events;
+
+DROP TABLE remember_tokens;
+
+DROP TABLE login_lockouts;
+
+DROP TABLE user_sessions;
+
+DROP TABLE user_identities;
+
+DROP TABLE recovery_codes;
+
+DROP TABLE tokens;
+
+DROP TABLE api_tokens;
+
+DROP TABLE webhook_deliveries;
+
+DROP TABLE webhooks;
+
 DROP TABLE users;
 
 DROP table snippets;
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// scopes of single-use tokens, a token is only valid for the scope it was created for
+const (
+	ScopeVerification  = "verification"
+	ScopePasswordReset = "password-reset"
+	ScopeEmailChange   = "email-change"
+)
+
+type TokenModelInterface interface {
+	New(userID int, scope string, ttl time.Duration) (string, error)
+	Consume(scope, token string) (int, error)
+	DeleteAllForUser(scope string, userID int) error
+}
+
+// single-use tokens sent by email, only their sha256 hash is stored
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// creates a token for the user that expires after ttl and returns it in plain text
+func (m *TokenModel) New(userID int, scope string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO tokens (hash, user_id, scope, expiry)
+	VALUES(?, ?, ?, ?)`
+
+	_, err = m.DB.Exec(query, hashToken(token), userID, scope, time.Now().Add(ttl).UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// returns the user the token was issued to and deletes it so it can't be used again,
+// fails with ErrInvalidToken if the token doesn't exist or expired
+func (m *TokenModel) Consume(scope, token string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var userID int
+	var expiry time.Time
+
+	query := `SELECT user_id, expiry FROM tokens WHERE hash = ? AND scope = ? FOR UPDATE`
+
+	err = tx.QueryRow(query, hashToken(token), scope).Scan(&userID, &expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidToken
+		} else {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM tokens WHERE hash = ?`, hashToken(token))
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	if time.Now().After(expiry) {
+		return 0, ErrInvalidToken
+	}
+
+	return userID, nil
+}
+
+// invalidates all outstanding tokens of a scope for the user
+func (m *TokenModel) DeleteAllForUser(scope string, userID int) error {
+	query := `DELETE FROM tokens WHERE scope = ? AND user_id = ?`
+
+	_, err := m.DB.Exec(query, scope, userID)
+	return err
+}
+
+// returns a hex encoded string of 32 random bytes, suitable for use in urls
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,314 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/totp"
+)
+
+// number of recovery codes generated when 2fa is enabled
+const RecoveryCodeCount = 10
+
+type TwoFactorModelInterface interface {
+	Secret(userID int) (string, error)
+	SetSecret(userID int, secret string) error
+	Enabled(userID int) (bool, error)
+	Enable(userID int) ([]string, error)
+	Disable(userID int) error
+	CheckCode(userID int, code string) error
+	UseRecoveryCode(userID int, code string) error
+	RecoveryCodesLeft(userID int) (int, error)
+	RegenerateRecoveryCodes(userID int) ([]string, error)
+}
+
+// totp secrets are stored encrypted with Key (AES-256-GCM), recovery codes as sha256 hashes
+type TwoFactorModel struct {
+	DB  *sql.DB
+	Key []byte
+}
+
+// returns the decrypted totp secret of the user, which may still be pending confirmation,
+// ErrNoRecord if the user has none
+func (m *TwoFactorModel) Secret(userID int) (string, error) {
+	var ciphertext []byte
+
+	query := `SELECT totp_secret FROM users WHERE id = ?`
+
+	err := m.DB.QueryRow(query, userID).Scan(&ciphertext)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+
+	if ciphertext == nil {
+		return "", ErrNoRecord
+	}
+
+	plaintext, err := m.decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// stores a new secret pending confirmation, doesn't touch users that already enabled 2fa
+func (m *TwoFactorModel) SetSecret(userID int, secret string) error {
+	ciphertext, err := m.encrypt([]byte(secret))
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ? AND totp_enabled = FALSE`
+
+	_, err = m.DB.Exec(query, ciphertext, userID)
+	return err
+}
+
+func (m *TwoFactorModel) Enabled(userID int) (bool, error) {
+	var enabled bool
+
+	query := `SELECT totp_enabled FROM users WHERE id = ?`
+
+	err := m.DB.QueryRow(query, userID).Scan(&enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrNoRecord
+		} else {
+			return false, err
+		}
+	}
+
+	return enabled, nil
+}
+
+// enables 2fa with the pending secret and returns a fresh set of recovery codes in plain text,
+// this is the only time they are available
+func (m *TwoFactorModel) Enable(userID int) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `UPDATE users SET totp_enabled = TRUE WHERE id = ? AND totp_secret IS NOT NULL`
+
+	result, err := tx.Exec(query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRowsAffected(result)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit()
+}
+
+// turns 2fa off and removes the secret and any remaining recovery codes
+func (m *TwoFactorModel) Disable(userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `UPDATE users SET totp_enabled = FALSE, totp_secret = NULL, totp_last_step = 0 WHERE id = ?`
+
+	_, err = tx.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checks a totp code against the secret of the user, fails with ErrInvalidCredentials
+// if it doesn't match or its time step was already used
+func (m *TwoFactorModel) CheckCode(userID int, code string) error {
+	secret, err := m.Secret(userID)
+	if err != nil {
+		if errors.Is(err, ErrNoRecord) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidCredentials
+	}
+
+	// only accept each code once, the condition makes the check and update atomic
+	query := `UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`
+
+	result, err := m.DB.Exec(query, step, userID, step)
+	if err != nil {
+		return err
+	}
+
+	err = checkRowsAffected(result)
+	if errors.Is(err, ErrNoRecord) {
+		return ErrInvalidCredentials
+	}
+
+	return err
+}
+
+// consumes a recovery code, fails with ErrInvalidCredentials if it doesn't exist
+func (m *TwoFactorModel) UseRecoveryCode(userID int, code string) error {
+	query := `DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?`
+
+	result, err := m.DB.Exec(query, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+
+	err = checkRowsAffected(result)
+	if errors.Is(err, ErrNoRecord) {
+		return ErrInvalidCredentials
+	}
+
+	return err
+}
+
+func (m *TwoFactorModel) RecoveryCodesLeft(userID int) (int, error) {
+	var n int
+
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?`
+
+	err := m.DB.QueryRow(query, userID).Scan(&n)
+	return n, err
+}
+
+// replaces all recovery codes of the user and returns the new ones in plain text
+func (m *TwoFactorModel) RegenerateRecoveryCodes(userID int) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+	_, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES(?, ?)`, userID, hashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// returns a code like "k3j9x-p2m4q", 50 random bits that are easy to type
+func randomRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+
+	return s[:5] + "-" + s[5:], nil
+}
+
+// recovery codes are compared without dashes, spaces and case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func (m *TwoFactorModel) encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := m.gcm()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// the nonce is stored in front of the ciphertext
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (m *TwoFactorModel) decrypt(ciphertext []byte) ([]byte, error) {
+	gcm, err := m.gcm()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("models: totp secret ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func (m *TwoFactorModel) gcm() (cipher.AEAD, error) {
+	if len(m.Key) != 32 {
+		return nil, fmt.Errorf("models: totp encryption key must be 32 bytes, got %d", len(m.Key))
+	}
+
+	block, err := aes.NewCipher(m.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -1,60 +1,178 @@
 package models
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
+	Insert(name, username, email, password string) (int, error)
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
 	Get(id int) (*User, error)
 	PasswordUpdate(id int, currentPassword, newPassword string) error
+	FeedToken(id int) (string, error)
+	ResetFeedToken(id int) (string, error)
+	GetByFeedToken(token string) (*User, error)
+	SetEmailVerified(id int) error
+	GetByEmail(email string) (*User, error)
+	PasswordReset(id int, newPassword string) error
+	InsertSSO(name, username, email string) (int, error)
+	CheckPassword(id int, password string) error
+	ScheduleDeletion(id int, mode string, at time.Time) error
+	CancelDeletion(id int) (bool, error)
+	DueDeletions() ([]int, error)
+	DeletionMode(id int) (string, error)
+	Delete(id int) error
+	RequestEmailChange(id int, password, email string) error
+	ConfirmEmailChange(id int) (string, error)
+	GetByUsername(username string) (*User, error)
+	SetRole(id int, role string) error
+	SetSuspended(id int, suspended bool) error
+	Search(query string, limit int) ([]*User, error)
+}
+
+// what happens to the snippets of a deleted account
+const (
+	DeletionModeDelete    = "delete"
+	DeletionModeAnonymize = "anonymize" // the snippets are kept without an owner
+)
+
+// roles from least to most privileged, every role includes the ones before it
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists the valid roles in order of privilege
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// reports whether role grants at least the privileges of required
+func RoleAtLeast(role, required string) bool {
+	return rankAtLeast(Roles, role, required)
+}
+
+// reports whether role comes at or after required in roles, which is ordered by privilege
+func rankAtLeast(roles []string, role, required string) bool {
+	rank := func(role string) int {
+		for i, r := range roles {
+			if r == role {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	return rank(role) >= rank(required) && rank(required) >= 0
 }
 
 // Represents a user in the database
 type User struct {
 	ID             int
 	Name           string
+	Username       string // unique and url safe, empty for accounts created before usernames existed
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	EmailVerified  bool
+	Role           string
+	Suspended      bool // suspended users can't log in or use the api
 }
 
 type UserModel struct {
 	DB *sql.DB
+	// hashes new passwords, DefaultPasswordHasher if nil, hashes of other algorithms
+	// or parameters are replaced when the user logs in
+	Hasher PasswordHasher
+
+	dummyOnce sync.Once
+	dummy     []byte
+	dummyErr  error
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+func (m *UserModel) hasher() PasswordHasher {
+	if m.Hasher == nil {
+		return DefaultPasswordHasher
+	}
+
+	return m.Hasher
+}
+
+// inserts a new user with an unverified email address and returns its id
+func (m *UserModel) Insert(name, username, email, password string) (int, error) {
+	hash, err := m.hasher().Hash(password)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	query := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	query := `INSERT INTO users (name, username, email, hashed_password, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
 
-	_, err = m.DB.Exec(query, name, email, hash)
+	result, err := m.DB.Exec(query, name, username, email, hash)
 	if err != nil {
-		// check if the error is a duplicate entry error for the email column
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			// users_uc_email is the unique constraint name
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		if isDuplicateKey(err, "users_uc_email") {
+			return 0, ErrDuplicateEmail
 		}
 
-		return err
+		if isDuplicateKey(err, "users_uc_username") {
+			return 0, ErrDuplicateUsername
+		}
+
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// inserts a user that signed in through single sign-on, the provider already verified
+// the email address and the random password can only be replaced through a password reset
+func (m *UserModel) InsertSSO(name, username, email string) (int, error) {
+	password, err := randomToken()
+	if err != nil {
+		return 0, err
+	}
+
+	hash, err := m.hasher().Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO users (name, username, email, hashed_password, created, email_verified)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), TRUE)`
+
+	result, err := m.DB.Exec(query, name, username, email, hash)
+	if err != nil {
+		if isDuplicateKey(err, "users_uc_email") {
+			return 0, ErrDuplicateEmail
+		}
+
+		if isDuplicateKey(err, "users_uc_username") {
+			return 0, ErrDuplicateUsername
+		}
+
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return int(id), nil
 }
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
@@ -67,6 +185,13 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	err := m.DB.QueryRow(query, email).Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			// takes as long as checking a real password, so the response time doesn't
+			// give away which email addresses have an account
+			err = m.compareDummy(password)
+			if err != nil && !errors.Is(err, ErrInvalidCredentials) {
+				return 0, err
+			}
+
 			return 0, ErrInvalidCredentials
 		} else {
 			return 0, err
@@ -74,11 +199,15 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	}
 
 	// compare the provided password with the hashed password
-	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	err = comparePassword(hashedPassword, password)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return 0, ErrInvalidCredentials
-		} else {
+		return 0, err
+	}
+
+	// the password is only known right now, so this is the chance to upgrade old hashes
+	if m.hasher().NeedsRehash(hashedPassword) {
+		err = m.rehash(id, hashedPassword, password)
+		if err != nil {
 			return 0, err
 		}
 	}
@@ -86,6 +215,40 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	return id, nil
 }
 
+// compares the password against the hash of a random one made by the current hasher,
+// the hash is created on first use
+func (m *UserModel) compareDummy(password string) error {
+	m.dummyOnce.Do(func() {
+		b := make([]byte, 16)
+
+		_, m.dummyErr = rand.Read(b)
+		if m.dummyErr != nil {
+			return
+		}
+
+		m.dummy, m.dummyErr = m.hasher().Hash(hex.EncodeToString(b))
+	})
+
+	if m.dummyErr != nil {
+		return m.dummyErr
+	}
+
+	return comparePassword(m.dummy, password)
+}
+
+// replaces the hash with one of the current hasher, unless the password changed meanwhile
+func (m *UserModel) rehash(id int, oldHash []byte, password string) error {
+	hash, err := m.hasher().Hash(password)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE users SET hashed_password = ? WHERE id = ? AND hashed_password = ?`
+
+	_, err = m.DB.Exec(query, hash, id, oldHash)
+	return err
+}
+
 func (m *UserModel) Exists(id int) (bool, error) {
 	var exists bool
 
@@ -97,11 +260,11 @@ func (m *UserModel) Exists(id int) (bool, error) {
 }
 
 func (m *UserModel) Get(id int) (*User, error) {
-	query := `SELECT id, name, email, created FROM users WHERE id = ?`
+	query := `SELECT id, name, COALESCE(username, ''), email, created, email_verified, role, suspended FROM users WHERE id = ?`
 
 	u := &User{}
 
-	err := m.DB.QueryRow(query, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created)
+	err := m.DB.QueryRow(query, id).Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Created, &u.EmailVerified, &u.Role, &u.Suspended)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -128,17 +291,13 @@ func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string)
 	}
 
 	// compare the current password with the stored hashed
-	err = bcrypt.CompareHashAndPassword(currentHash, []byte(currentPassword))
+	err = comparePassword(currentHash, currentPassword)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return ErrInvalidCredentials
-		} else {
-			return err
-		}
+		return err
 	}
 
 	// once we know the current password is correct, generate a new hash for the new password
-	newHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	newHash, err := m.hasher().Hash(newPassword)
 	if err != nil {
 		return err
 	}
@@ -148,3 +307,452 @@ func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string)
 	_, err = m.DB.Exec(query, newHash, id)
 	return err
 }
+
+// returns the private feed token of the user, generating one on first use
+func (m *UserModel) FeedToken(id int) (string, error) {
+	var token sql.NullString
+
+	query := `SELECT feed_token FROM users WHERE id = ?`
+
+	err := m.DB.QueryRow(query, id).Scan(&token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+
+	if token.Valid {
+		return token.String, nil
+	}
+
+	return m.ResetFeedToken(id)
+}
+
+// replaces the private feed token of the user, invalidating the old feed urls
+func (m *UserModel) ResetFeedToken(id int) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	query := `UPDATE users SET feed_token = ? WHERE id = ?`
+
+	_, err = m.DB.Exec(query, token, id)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (m *UserModel) GetByFeedToken(token string) (*User, error) {
+	query := `SELECT id, name, COALESCE(username, ''), email, created FROM users WHERE feed_token = ?`
+
+	u := &User{}
+
+	err := m.DB.QueryRow(query, token).Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+func (m *UserModel) SetEmailVerified(id int) error {
+	query := `UPDATE users SET email_verified = TRUE WHERE id = ?`
+
+	_, err := m.DB.Exec(query, id)
+	return err
+}
+
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	query := `SELECT id, name, COALESCE(username, ''), email, created, email_verified, role, suspended FROM users WHERE email = ?`
+
+	u := &User{}
+
+	err := m.DB.QueryRow(query, email).Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Created, &u.EmailVerified, &u.Role, &u.Suspended)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+func (m *UserModel) GetByUsername(username string) (*User, error) {
+	query := `SELECT id, name, username, email, created, email_verified, role, suspended FROM users WHERE username = ?`
+
+	u := &User{}
+
+	err := m.DB.QueryRow(query, username).Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Created, &u.EmailVerified, &u.Role, &u.Suspended)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+// sets a new password without knowing the current one
+func (m *UserModel) PasswordReset(id int, newPassword string) error {
+	hash, err := m.hasher().Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE users SET hashed_password = ? WHERE id = ?`
+
+	result, err := m.DB.Exec(query, hash, id)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// marks the account for deletion at the given time, callers confirm the deletion with
+// CheckPassword first or, for accounts without a known password, a fresh sso login
+func (m *UserModel) ScheduleDeletion(id int, mode string, at time.Time) error {
+	query := `UPDATE users SET deletion_scheduled = ?, deletion_mode = ? WHERE id = ?`
+
+	_, err := m.DB.Exec(query, at.UTC(), mode, id)
+	return err
+}
+
+// cancels a scheduled deletion and reports whether there was one
+func (m *UserModel) CancelDeletion(id int) (bool, error) {
+	query := `UPDATE users SET deletion_scheduled = NULL, deletion_mode = NULL
+	WHERE id = ? AND deletion_scheduled IS NOT NULL`
+
+	result, err := m.DB.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// returns the ids of the accounts whose grace period is over
+func (m *UserModel) DueDeletions() ([]int, error) {
+	query := `SELECT id FROM users WHERE deletion_scheduled <= UTC_TIMESTAMP()`
+
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []int{}
+
+	for rows.Next() {
+		var id int
+
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// returns what happens to the snippets of a user scheduled for deletion
+func (m *UserModel) DeletionMode(id int) (string, error) {
+	var mode sql.NullString
+
+	err := m.DB.QueryRow(`SELECT deletion_mode FROM users WHERE id = ?`, id).Scan(&mode)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+
+	return mode.String, nil
+}
+
+// removes the user and everything that belongs to them in one transaction, their snippets
+// are deleted or kept without an owner depending on the deletion mode they picked
+func (m *UserModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var mode sql.NullString
+	var email string
+
+	err = tx.QueryRow(`SELECT deletion_mode, email FROM users WHERE id = ? FOR UPDATE`, id).Scan(&mode, &email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	// shares, stars and comments of deleted snippets go along with them
+	snippets := []string{
+		`DELETE FROM snippet_shares WHERE snippet_id IN (SELECT id FROM snippets WHERE user_id = ? AND team_id IS NULL)`,
+		`DELETE FROM stars WHERE snippet_id IN (SELECT id FROM snippets WHERE user_id = ? AND team_id IS NULL)`,
+		`DELETE FROM comments WHERE snippet_id IN (SELECT id FROM snippets WHERE user_id = ? AND team_id IS NULL)`,
+		`DELETE FROM snippets WHERE user_id = ? AND team_id IS NULL`,
+	}
+	if mode.String == DeletionModeAnonymize {
+		snippets = []string{`UPDATE snippets SET user_id = NULL WHERE user_id = ?`}
+	}
+
+	queries := append(snippets,
+		`DELETE FROM snippet_shares WHERE user_id = ?`,
+		`DELETE FROM share_links WHERE created_by = ?`,
+		`DELETE FROM stars WHERE user_id = ?`,
+		// comments on other snippets are kept so their threads still make sense
+		`UPDATE comments SET user_id = NULL WHERE user_id = ?`,
+		// team snippets belong to the team, they're always kept
+		`UPDATE snippets SET user_id = NULL WHERE user_id = ?`,
+		`DELETE FROM team_members WHERE user_id = ?`,
+		`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE user_id = ?)`,
+		`DELETE FROM webhooks WHERE user_id = ?`,
+		`DELETE FROM api_tokens WHERE user_id = ?`,
+		`DELETE FROM tokens WHERE user_id = ?`,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+		`DELETE FROM user_identities WHERE user_id = ?`,
+		`DELETE FROM user_sessions WHERE user_id = ?`,
+		`DELETE FROM login_lockouts WHERE user_id = ?`,
+		`DELETE FROM remember_tokens WHERE user_id = ?`,
+		`DELETE FROM users WHERE id = ?`,
+	)
+
+	for _, query := range queries {
+		_, err = tx.Exec(query, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the audit events are kept, but without anything that identifies the person behind them,
+	// failed logins with an address the account doesn't have at the time are recorded without
+	// the user, so the earlier addresses are taken from the email changes ("old to new")
+	emails := []any{email}
+
+	rows, err := tx.Query(`SELECT detail FROM audit_events WHERE user_id = ? AND action = ?`, id, AuditEmailChange)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var detail string
+
+		err = rows.Scan(&detail)
+		if err != nil {
+			return err
+		}
+
+		if old, _, ok := strings.Cut(detail, " to "); ok && old != "" {
+			emails = append(emails, old)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	query := `UPDATE audit_events SET ip = '', user_agent = '',
+	detail = IF(action IN (?, ?), '', detail)
+	WHERE user_id = ? OR (user_id IS NULL AND action = ? AND detail IN (?` + strings.Repeat(", ?", len(emails)-1) + `))`
+
+	args := append([]any{AuditLoginFailed, AuditEmailChange, id, AuditLoginFailed}, emails...)
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// stores the address the user wants to switch to until they confirm it, the password
+// has to be confirmed and the address can't belong to another account
+func (m *UserModel) RequestEmailChange(id int, password, email string) error {
+	err := m.CheckPassword(id, password)
+	if err != nil {
+		return err
+	}
+
+	var taken bool
+
+	err = m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM users WHERE email = ?)`, email).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrDuplicateEmail
+	}
+
+	_, err = m.DB.Exec(`UPDATE users SET pending_email = ? WHERE id = ?`, email, id)
+	return err
+}
+
+// switches to the pending email address, which counts as verified since the confirmation
+// link was sent there, and returns the old address
+func (m *UserModel) ConfirmEmailChange(id int) (string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+
+	defer tx.Rollback()
+
+	var email string
+	var pending sql.NullString
+
+	query := `SELECT email, pending_email FROM users WHERE id = ? FOR UPDATE`
+
+	err = tx.QueryRow(query, id).Scan(&email, &pending)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+
+	if !pending.Valid {
+		return "", ErrNoRecord
+	}
+
+	query = `UPDATE users SET email = pending_email, pending_email = NULL, email_verified = TRUE
+	WHERE id = ?`
+
+	_, err = tx.Exec(query, id)
+	if err != nil {
+		// someone else signed up with the address since the change was requested
+		if isDuplicateKey(err, "users_uc_email") {
+			return "", ErrDuplicateEmail
+		}
+
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return email, nil
+}
+
+// fails with ErrInvalidCredentials unless password is the user's current password
+func (m *UserModel) CheckPassword(id int, password string) error {
+	var hash []byte
+
+	err := m.DB.QueryRow(`SELECT hashed_password FROM users WHERE id = ?`, id).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	return comparePassword(hash, password)
+}
+
+// reports whether err is a duplicate entry error for the named unique constraint
+func isDuplicateKey(err error, constraint string) bool {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, constraint)
+	}
+
+	return false
+}
+
+func (m *UserModel) SetRole(id int, role string) error {
+	result, err := m.DB.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, id)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+func (m *UserModel) SetSuspended(id int, suspended bool) error {
+	result, err := m.DB.Exec(`UPDATE users SET suspended = ? WHERE id = ?`, suspended, id)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// returns the users whose name, username or email address contains query,
+// newest first, all users if query is empty
+func (m *UserModel) Search(query string, limit int) ([]*User, error) {
+	pattern := "%" + escapeLike(query) + "%"
+
+	stmt := `SELECT id, name, COALESCE(username, ''), email, created, email_verified, role, suspended
+	FROM users WHERE name LIKE ? OR username LIKE ? OR email LIKE ?
+	ORDER BY created DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, pattern, pattern, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []*User{}
+
+	for rows.Next() {
+		u := &User{}
+
+		err = rows.Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Created, &u.EmailVerified, &u.Role, &u.Suspended)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// escapes the wildcards of a LIKE pattern so they match literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -38,7 +38,7 @@ func TestUserModelExists(t *testing.T) {
 			// each test case sets up a clean instance of the test database
 			db := newTestDb(t)
 
-			m := UserModel{db}
+			m := UserModel{DB: db}
 
 			exists, err := m.Exists(tt.userID)
 
@@ -48,3 +48,36 @@ func TestUserModelExists(t *testing.T) {
 		})
 	}
 }
+
+func TestUserModelDeleteAuditEvents(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDb(t)
+
+	// the user changed their address from old@ to mocky@, the failed logins with old@
+	// after the change and the one with an unrelated address have no user
+	_, err := db.Exec(`INSERT INTO audit_events (user_id, action, ip, user_agent, detail, created) VALUES
+	(1, ?, '192.0.2.1', 'test', 'old@example.com to mocky@example.com', UTC_TIMESTAMP()),
+	(NULL, ?, '192.0.2.2', 'test', 'old@example.com', UTC_TIMESTAMP()),
+	(NULL, ?, '192.0.2.3', 'test', 'mocky@example.com', UTC_TIMESTAMP()),
+	(NULL, ?, '192.0.2.4', 'test', 'someone@example.com', UTC_TIMESTAMP())`,
+		AuditEmailChange, AuditLoginFailed, AuditLoginFailed, AuditLoginFailed)
+	assert.NilError(t, err)
+
+	m := UserModel{DB: db}
+
+	err = m.Delete(1)
+	assert.NilError(t, err)
+
+	var identifying int
+	err = db.QueryRow(`SELECT COUNT(*) FROM audit_events WHERE ip != '' OR detail LIKE '%mocky%' OR detail LIKE '%old@%'`).Scan(&identifying)
+	assert.NilError(t, err)
+	assert.Equal(t, identifying, 1)
+
+	var detail string
+	err = db.QueryRow(`SELECT detail FROM audit_events WHERE ip != ''`).Scan(&detail)
+	assert.NilError(t, err)
+	assert.Equal(t, detail, "someone@example.com")
+}
@@ -0,0 +1,329 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// events that can be subscribed to by a webhook
+const (
+	EventSnippetCreated = "snippet.created"
+	EventSnippetUpdated = "snippet.updated"
+	EventSnippetDeleted = "snippet.deleted"
+	EventSnippetExpired = "snippet.expired"
+)
+
+var WebhookEvents = []string{
+	EventSnippetCreated,
+	EventSnippetUpdated,
+	EventSnippetDeleted,
+	EventSnippetExpired,
+}
+
+// delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookModelInterface interface {
+	Insert(userID int, url, secret string, events []string) (int, error)
+	Get(id, userID int) (*Webhook, error)
+	ForUser(userID int) ([]*Webhook, error)
+	Delete(id, userID int) error
+	Enqueue(userID int, event string, payload []byte) error
+	Deliveries(webhookID int) ([]*WebhookDelivery, error)
+	Due(limit int) ([]*WebhookDelivery, error)
+	PendingForUser(userID int) ([]*WebhookDelivery, error)
+	MarkDelivered(id, responseCode int) error
+	MarkFailed(id, responseCode int, lastError string, nextAttempt time.Time, giveUp bool) error
+	Prune(before time.Time) error
+}
+
+// Represents a webhook registered by a user
+type Webhook struct {
+	ID      int
+	UserID  int
+	URL     string
+	Secret  string
+	Events  []string
+	Created time.Time
+}
+
+// reports whether the webhook is subscribed to the given event
+func (w *Webhook) Subscribed(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Represents a single queued delivery of an event to a webhook
+type WebhookDelivery struct {
+	ID           int
+	WebhookID    int
+	Event        string
+	Payload      []byte
+	Status       string
+	Attempts     int
+	ResponseCode int // 0 if no response was received
+	LastError    string
+	NextAttempt  time.Time
+	Created      time.Time
+	// target of the delivery, only populated by Due()
+	URL    string
+	Secret string
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+func (m *WebhookModel) Insert(userID int, url, secret string, events []string) (int, error) {
+	query := `INSERT INTO webhooks (user_id, url, secret, events, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(query, userID, url, secret, strings.Join(events, ","))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func (m *WebhookModel) Get(id, userID int) (*Webhook, error) {
+	query := `SELECT id, user_id, url, secret, events, created FROM webhooks
+	WHERE id = ? AND user_id = ?`
+
+	w := &Webhook{}
+	var events string
+
+	err := m.DB.QueryRow(query, id, userID).Scan(&w.ID, &w.UserID, &w.URL, &w.Secret, &events, &w.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+
+		return nil, err
+	}
+
+	w.Events = strings.Split(events, ",")
+
+	return w, nil
+}
+
+func (m *WebhookModel) ForUser(userID int) ([]*Webhook, error) {
+	query := `SELECT id, user_id, url, secret, events, created FROM webhooks
+	WHERE user_id = ? ORDER BY created DESC`
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		w := &Webhook{}
+		var events string
+
+		err := rows.Scan(&w.ID, &w.UserID, &w.URL, &w.Secret, &events, &w.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		w.Events = strings.Split(events, ",")
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// deletes a webhook and its delivery log
+func (m *WebhookModel) Delete(id, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = checkRowsAffected(result); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// queues a delivery of the payload to every webhook of the user subscribed to the event
+func (m *WebhookModel) Enqueue(userID int, event string, payload []byte) error {
+	webhooks, err := m.ForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt, created)
+	VALUES(?, ?, ?, ?, 0, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+
+	for _, w := range webhooks {
+		if !w.Subscribed(event) {
+			continue
+		}
+
+		_, err = m.DB.Exec(query, w.ID, event, payload, DeliveryPending)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns the 50 most recent deliveries of a webhook
+func (m *WebhookModel) Deliveries(webhookID int) ([]*WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, event, payload, status, attempts, COALESCE(response_code, 0),
+	last_error, next_attempt, created FROM webhook_deliveries
+	WHERE webhook_id = ? ORDER BY created DESC, id DESC LIMIT 50`
+
+	rows, err := m.DB.Query(query, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		d := &WebhookDelivery{}
+
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.ResponseCode, &d.LastError, &d.NextAttempt, &d.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// returns up to limit pending deliveries whose next attempt is due, oldest first
+func (m *WebhookModel) Due(limit int) ([]*WebhookDelivery, error) {
+	query := `SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, COALESCE(d.response_code, 0),
+	d.last_error, d.next_attempt, d.created, w.url, w.secret
+	FROM webhook_deliveries d INNER JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.status = ? AND d.next_attempt <= UTC_TIMESTAMP()
+	ORDER BY d.next_attempt LIMIT ?`
+
+	return m.pending(query, DeliveryPending, limit)
+}
+
+// returns all pending deliveries to the webhooks of the user, due or not, oldest first
+func (m *WebhookModel) PendingForUser(userID int) ([]*WebhookDelivery, error) {
+	query := `SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, COALESCE(d.response_code, 0),
+	d.last_error, d.next_attempt, d.created, w.url, w.secret
+	FROM webhook_deliveries d INNER JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.status = ? AND w.user_id = ?
+	ORDER BY d.id`
+
+	return m.pending(query, DeliveryPending, userID)
+}
+
+// runs a query selecting deliveries along with the url and secret of their webhook
+func (m *WebhookModel) pending(query string, args ...any) ([]*WebhookDelivery, error) {
+	rows, err := m.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		d := &WebhookDelivery{}
+
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.ResponseCode, &d.LastError, &d.NextAttempt, &d.Created, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (m *WebhookModel) MarkDelivered(id, responseCode int) error {
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1,
+	response_code = ?, last_error = '' WHERE id = ?`
+
+	_, err := m.DB.Exec(query, DeliveryDelivered, responseCode, id)
+	return err
+}
+
+// records a failed attempt, the delivery is retried at nextAttempt unless giveUp is set
+func (m *WebhookModel) MarkFailed(id, responseCode int, lastError string, nextAttempt time.Time, giveUp bool) error {
+	status := DeliveryPending
+	if giveUp {
+		status = DeliveryFailed
+	}
+
+	// a zero response code means the request never got a response
+	var code sql.NullInt64
+	if responseCode != 0 {
+		code = sql.NullInt64{Int64: int64(responseCode), Valid: true}
+	}
+
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1,
+	response_code = ?, last_error = ?, next_attempt = ? WHERE id = ?`
+
+	_, err := m.DB.Exec(query, status, code, lastError, nextAttempt.UTC(), id)
+	return err
+}
+
+// deletes delivered and failed deliveries created before the given time, pending ones are kept
+func (m *WebhookModel) Prune(before time.Time) error {
+	query := `DELETE FROM webhook_deliveries WHERE status <> ? AND created < ?`
+
+	_, err := m.DB.Exec(query, DeliveryPending, before.UTC())
+	return err
+}
@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// FailureConfig describes how a FailureTracker slows down and locks out keys
+// that keep failing, e.g. login attempts for an account or from an ip address
+type FailureConfig struct {
+	// failures allowed without any delay
+	FreeAttempts int
+	// delay after the first failure beyond FreeAttempts, doubled for every further one up to MaxDelay
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// number of failures after which the key is locked out for LockoutDuration
+	Threshold       int
+	LockoutDuration time.Duration
+	// failures are forgotten once the key hasn't failed for this long,
+	// falls back to LockoutDuration
+	Window time.Duration
+}
+
+type failures struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// FailureTracker counts consecutive failures per key and tells callers how long
+// to wait before the next attempt, safe for concurrent use
+type FailureTracker struct {
+	mu        sync.Mutex
+	cfg       FailureConfig
+	entries   map[string]*failures
+	lastSweep time.Time
+	now       func() time.Time // overridable in tests
+}
+
+func NewFailureTracker(cfg FailureConfig) *FailureTracker {
+	if cfg.Window == 0 {
+		cfg.Window = cfg.LockoutDuration
+	}
+
+	return &FailureTracker{
+		cfg:     cfg,
+		entries: make(map[string]*failures),
+		now:     time.Now,
+	}
+}
+
+// Wait returns how long the key has to wait before its next attempt, 0 if it may try now
+func (t *FailureTracker) Wait(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	f, ok := t.entries[key]
+	if !ok {
+		return 0
+	}
+
+	if now.Before(f.lockedUntil) {
+		return f.lockedUntil.Sub(now)
+	}
+
+	if wait := f.lastFailure.Add(t.delay(f.count)).Sub(now); wait > 0 {
+		return wait
+	}
+
+	return 0
+}
+
+// Fail records a failure for the key and reports whether it caused a lockout
+func (t *FailureTracker) Fail(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	// evict stale entries at most once per window, like MemoryLimiter does
+	if now.Sub(t.lastSweep) >= t.cfg.Window {
+		t.sweep(now)
+	}
+
+	f, ok := t.entries[key]
+	if !ok || t.expired(f, now) {
+		f = &failures{}
+		t.entries[key] = f
+	}
+
+	f.count++
+	f.lastFailure = now
+
+	if t.cfg.Threshold > 0 && f.count >= t.cfg.Threshold {
+		// start over once the lockout ends, so the key gets its free attempts back
+		f.count = 0
+		f.lockedUntil = now.Add(t.cfg.LockoutDuration)
+		return true
+	}
+
+	return false
+}
+
+// Reset forgets the failures of the key, e.g. after a successful login
+func (t *FailureTracker) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// Len returns the number of tracked keys
+func (t *FailureTracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.entries)
+}
+
+// delay required after count failures
+func (t *FailureTracker) delay(count int) time.Duration {
+	n := count - t.cfg.FreeAttempts
+	if n <= 0 || t.cfg.Delay == 0 {
+		return 0
+	}
+
+	d := t.cfg.Delay
+	for i := 1; i < n && d < t.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+
+	if t.cfg.MaxDelay > 0 && d > t.cfg.MaxDelay {
+		d = t.cfg.MaxDelay
+	}
+
+	return d
+}
+
+func (t *FailureTracker) expired(f *failures, now time.Time) bool {
+	return now.After(f.lockedUntil) && now.Sub(f.lastFailure) >= t.cfg.Window
+}
+
+func (t *FailureTracker) sweep(now time.Time) {
+	for key, f := range t.entries {
+		if t.expired(f, now) {
+			delete(t.entries, key)
+		}
+	}
+
+	t.lastSweep = now
+}
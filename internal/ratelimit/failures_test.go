@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+func TestFailureTracker(t *testing.T) {
+	now := time.Date(2023, 5, 22, 11, 55, 0, 0, time.UTC)
+
+	ft := NewFailureTracker(FailureConfig{
+		FreeAttempts:    2,
+		Delay:           time.Second,
+		MaxDelay:        4 * time.Second,
+		Threshold:       6,
+		LockoutDuration: time.Minute,
+	})
+	ft.now = func() time.Time { return now }
+
+	// the free attempts don't cause any delay
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, ft.Fail("foo"), false)
+		assert.Equal(t, ft.Wait("foo"), time.Duration(0))
+	}
+
+	// then the delay doubles with every failure, up to the maximum
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		assert.Equal(t, ft.Fail("foo"), false)
+		assert.Equal(t, ft.Wait("foo"), want)
+
+		now = now.Add(want)
+		assert.Equal(t, ft.Wait("foo"), time.Duration(0))
+	}
+
+	// other keys are tracked separately
+	assert.Equal(t, ft.Wait("bar"), time.Duration(0))
+
+	// reaching the threshold locks the key out
+	assert.Equal(t, ft.Fail("foo"), true)
+	assert.Equal(t, ft.Wait("foo"), time.Minute)
+
+	// after the lockout the key starts over
+	now = now.Add(time.Minute)
+	assert.Equal(t, ft.Wait("foo"), time.Duration(0))
+	assert.Equal(t, ft.Fail("foo"), false)
+	assert.Equal(t, ft.Wait("foo"), time.Duration(0))
+
+	ft.Reset("foo")
+	assert.Equal(t, ft.Len(), 0)
+}
+
+func TestFailureTrackerWindow(t *testing.T) {
+	now := time.Date(2023, 5, 22, 11, 55, 0, 0, time.UTC)
+
+	ft := NewFailureTracker(FailureConfig{
+		Threshold:       2,
+		LockoutDuration: time.Minute,
+		Window:          10 * time.Minute,
+	})
+	ft.now = func() time.Time { return now }
+
+	assert.Equal(t, ft.Fail("foo"), false)
+
+	// failures older than the window are forgotten
+	now = now.Add(10 * time.Minute)
+	assert.Equal(t, ft.Fail("foo"), false)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, ft.Fail("foo"), true)
+
+	// stale keys are evicted on the next failure after the window
+	now = now.Add(time.Hour)
+	ft.Fail("bar")
+	assert.Equal(t, ft.Len(), 1)
+}
@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter decides whether a request identified by key is allowed to proceed.
+// When it isn't, the returned duration is how long the client should wait before retrying.
+type Limiter interface {
+	Allow(key string) (bool, time.Duration)
+}
+
+// Config describes a token bucket: Burst tokens that refill at Rate tokens per second
+type Config struct {
+	Rate  float64
+	Burst int
+	// buckets that haven't been touched for IdleTTL are evicted,
+	// falls back to the time it takes to fully refill a bucket
+	IdleTTL time.Duration
+}
+
+// Per returns a rate of n tokens per duration d
+func Per(n int, d time.Duration) float64 {
+	return float64(n) / d.Seconds()
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// in-memory token bucket limiter, safe for concurrent use
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	cfg       Config
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time // overridable in tests
+}
+
+func NewMemoryLimiter(cfg Config) *MemoryLimiter {
+	if cfg.IdleTTL == 0 {
+		cfg.IdleTTL = time.Duration(float64(cfg.Burst) / cfg.Rate * float64(time.Second))
+	}
+
+	return &MemoryLimiter{
+		cfg:     cfg,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	// evict idle buckets at most once per IdleTTL so memory stays bounded
+	// without needing a background goroutine
+	if now.Sub(l.lastSweep) >= l.cfg.IdleTTL {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.cfg.Burst), lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	// refill the bucket for the time elapsed since the last request
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(l.cfg.Burst), b.tokens+elapsed*l.cfg.Rate)
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	// time until a full token is available again
+	wait := time.Duration((1 - b.tokens) / l.cfg.Rate * float64(time.Second))
+
+	return false, wait
+}
+
+// Len returns the number of tracked keys
+func (l *MemoryLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.cfg.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	now := time.Date(2023, 5, 22, 11, 55, 0, 0, time.UTC)
+
+	l := NewMemoryLimiter(Config{Rate: Per(1, time.Second), Burst: 2})
+	l.now = func() time.Time { return now }
+
+	// the burst is allowed straight away
+	for i := 0; i < 2; i++ {
+		ok, _ := l.Allow("foo")
+		assert.Equal(t, ok, true)
+	}
+
+	// the bucket is empty, so the client has to wait for a full token
+	ok, wait := l.Allow("foo")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, wait, time.Second)
+
+	// other keys have their own bucket
+	ok, _ = l.Allow("bar")
+	assert.Equal(t, ok, true)
+
+	now = now.Add(time.Second)
+	ok, _ = l.Allow("foo")
+	assert.Equal(t, ok, true)
+}
+
+func TestMemoryLimiterEviction(t *testing.T) {
+	now := time.Date(2023, 5, 22, 11, 55, 0, 0, time.UTC)
+
+	l := NewMemoryLimiter(Config{Rate: Per(1, time.Second), Burst: 1, IdleTTL: time.Minute})
+	l.now = func() time.Time { return now }
+
+	l.Allow("foo")
+	l.Allow("bar")
+	assert.Equal(t, l.Len(), 2)
+
+	// both keys are idle for longer than IdleTTL and get evicted on the next call
+	now = now.Add(2 * time.Minute)
+	l.Allow("baz")
+	assert.Equal(t, l.Len(), 1)
+}
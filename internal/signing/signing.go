@@ -0,0 +1,84 @@
+// Package signing signs messages with HMAC-SHA256 using a list of keys, so keys can be
+// rotated: new signatures use the first key and signatures made with any key still verify.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// the shortest key accepted, shorter keys are easier to brute-force than the signatures
+const MinKeyLength = 32
+
+var encoding = base64.RawURLEncoding
+
+type Signer struct {
+	keys [][]byte
+}
+
+// New returns a signer for the given keys, the first one signs and all of them verify
+func New(keys ...[]byte) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("signing: no keys")
+	}
+
+	for _, key := range keys {
+		if len(key) < MinKeyLength {
+			return nil, errors.New("signing: keys must be at least 32 bytes")
+		}
+	}
+
+	return &Signer{keys: keys}, nil
+}
+
+// ParseKeys parses a space or comma separated list of hex encoded keys, newest first
+func ParseKeys(s string) ([][]byte, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	keys := [][]byte{}
+
+	for _, field := range fields {
+		key, err := hex.DecodeString(field)
+		if err != nil {
+			return nil, errors.New("signing: keys must be hex encoded")
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Sign returns the url safe signature of the message made with the newest key
+func (s *Signer) Sign(message string) string {
+	return encoding.EncodeToString(sum(s.keys[0], message))
+}
+
+// Verify reports whether the signature was made for the message with any of the keys
+func (s *Signer) Verify(message, signature string) bool {
+	mac, err := encoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	for _, key := range s.keys {
+		if hmac.Equal(mac, sum(key, message)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sum(key []byte, message string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(message))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,64 @@
+package signing
+
+import (
+	"bytes"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+func TestVerify(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, MinKeyLength)
+	newKey := bytes.Repeat([]byte{2}, MinKeyLength)
+
+	oldSigner, err := New(oldKey)
+	assert.NilError(t, err)
+
+	// rotated signer, signs with the new key and still accepts the old one
+	signer, err := New(newKey, oldKey)
+	assert.NilError(t, err)
+
+	otherSigner, err := New(bytes.Repeat([]byte{3}, MinKeyLength))
+	assert.NilError(t, err)
+
+	signature := signer.Sign("4.1700000000")
+
+	tests := []struct {
+		name      string
+		message   string
+		signature string
+		want      bool
+	}{
+		{"Valid", "4.1700000000", signature, true},
+		{"Old key", "4.1700000000", oldSigner.Sign("4.1700000000"), true},
+		{"Unknown key", "4.1700000000", otherSigner.Sign("4.1700000000"), false},
+		{"Changed message", "4.1800000000", signature, false},
+		{"Truncated", "4.1700000000", signature[:10], false},
+		{"Not base64", "4.1700000000", "not a signature!", false},
+		{"Empty", "4.1700000000", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, signer.Verify(tt.message, tt.signature), tt.want)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New()
+	assert.Equal(t, err != nil, true)
+
+	_, err = New(bytes.Repeat([]byte{1}, MinKeyLength-1))
+	assert.Equal(t, err != nil, true)
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys("0a0b, 0c0d 0e")
+	assert.NilError(t, err)
+	assert.Equal(t, len(keys), 3)
+	assert.Equal(t, keys[0][1], byte(0x0b))
+
+	_, err = ParseKeys("nothex")
+	assert.Equal(t, err != nil, true)
+}
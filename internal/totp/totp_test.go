@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+// sha1 test vectors from https://www.rfc-editor.org/rfc/rfc6238#appendix-B,
+// truncated to the last 6 digits
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		assert.NilError(t, err)
+		assert.Equal(t, code, tt.want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NilError(t, err)
+
+	now := time.Date(2023, 5, 22, 11, 55, 0, 0, time.UTC)
+	code, err := Code(secret, Step(now))
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name   string
+		code   string
+		at     time.Time
+		wantOK bool
+	}{
+		{"Current step", code, now, true},
+		{"Spaces are ignored", code[:3] + " " + code[3:], now, true},
+		{"Previous step", code, now.Add(Period), true},
+		{"Too old", code, now.Add(2 * Period), false},
+		{"Wrong length", code[:5], now, false},
+		{"Wrong code", strings.Repeat("0", Digits), now, code == strings.Repeat("0", Digits)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(secret, tt.code, tt.at)
+			assert.Equal(t, ok, tt.wantOK)
+
+			if ok {
+				assert.Equal(t, step, Step(now))
+			}
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	got := URL("GoSnipIt", "mocked@example.com", "JBSWY3DPEHPK3PXP")
+	assert.Equal(t, got, "otpauth://totp/GoSnipIt:mocked@example.com?algorithm=SHA1&digits=6&issuer=GoSnipIt&period=30&secret=JBSWY3DPEHPK3PXP")
+}
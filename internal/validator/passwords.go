@@ -0,0 +1,399 @@
+package validator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+)
+
+//go:embed data/common-passwords.txt
+var commonPasswordsFile string
+
+//go:embed data/words.txt
+var wordsFile string
+
+var (
+	// passwords that are refused outright
+	commonPasswords = toSet(strings.Fields(commonPasswordsFile))
+	// words that only cost a guess from a short list when they're part of a password
+	dictionary = append(strings.Fields(wordsFile), strings.Fields(commonPasswordsFile)...)
+	// runs along these count as sequences just like abc or 123
+	keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890"}
+)
+
+// lower bound of the entropy estimate of acceptable passwords, in bits
+const DefaultMinPasswordEntropy = 36
+
+// decides whether a password is good enough, the reasons for refusing a password
+// are meant to be shown to the user
+type PasswordPolicy struct {
+	MinEntropy float64            // in bits
+	Breached   *BreachedPasswords // optional, refuses passwords found in the breached hash file
+}
+
+// returns why the password is refused, or an empty string if it's acceptable,
+// userInputs are the name, username and email address of the user which make poor passwords
+func (p *PasswordPolicy) Check(password string, userInputs ...string) (string, error) {
+	if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+		return "This password is one of the most commonly used passwords", nil
+	}
+
+	bits, weakness := PasswordEntropy(password, userInputs...)
+	if bits < p.MinEntropy {
+		switch weakness {
+		case weaknessPersonal:
+			return "This password is too easy to guess, it is based on your name, username or email address", nil
+		case weaknessDictionary:
+			return "This password is too easy to guess, it is mostly made of common words", nil
+		case weaknessSequence:
+			return "This password is too easy to guess, it contains sequences like abc, 123 or qwerty", nil
+		case weaknessRepeat:
+			return "This password is too easy to guess, it repeats the same character", nil
+		default:
+			return "This password is too easy to guess, make it longer or mix in other kinds of characters", nil
+		}
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return "", err
+		}
+
+		if breached {
+			return "This password has appeared in a data breach and can't be used", nil
+		}
+	}
+
+	return "", nil
+}
+
+// the kinds of patterns that make a password easier to guess
+const (
+	weaknessNone       = ""
+	weaknessPersonal   = "personal"
+	weaknessDictionary = "dictionary"
+	weaknessSequence   = "sequence"
+	weaknessRepeat     = "repeat"
+)
+
+// estimates how many bits of entropy the password has, assuming an attacker tries
+// dictionary words, the user's own details, sequences and repeated characters first,
+// also returns the kind of pattern that weakened the password the most
+func PasswordEntropy(password string, userInputs ...string) (float64, string) {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	unleeted := []rune(unleet(strings.ToLower(password)))
+	perChar := math.Log2(charsetSize(runes))
+	personal := personalTokens(userInputs)
+
+	var bits float64
+	savings := map[string]float64{}
+
+	for i := 0; i < len(runes); {
+		length, cost, kind := 1, perChar, weaknessNone
+
+		// the longest match wins, a longer pattern explains more of the password
+		try := func(l int, c float64, k string) {
+			if l > length || (l == length && c < cost) {
+				length, cost, kind = l, c, k
+			}
+		}
+
+		for _, token := range personal {
+			if hasPrefix(lower[i:], token) {
+				try(len(token), 1, weaknessPersonal)
+			}
+		}
+
+		for _, word := range dictionary {
+			if len(word) >= 4 && hasPrefix(unleeted[i:], []rune(word)) {
+				c := math.Log2(float64(len(dictionary)))
+				if string(runes[i:i+len(word)]) != string(lower[i:i+len(word)]) {
+					c++ // capitalized
+				}
+				if string(lower[i:i+len(word)]) != word {
+					c++ // leetspeak
+				}
+
+				try(len(word), c, weaknessDictionary)
+			}
+		}
+
+		if l := repeatLength(lower[i:]); l >= 3 {
+			try(l, perChar+math.Log2(float64(l)), weaknessRepeat)
+		}
+
+		if l := sequenceLength(lower[i:]); l >= 3 {
+			try(l, perChar+math.Log2(float64(l))+1, weaknessSequence)
+		}
+
+		bits += cost
+		if kind != weaknessNone {
+			savings[kind] += perChar*float64(length) - cost
+		}
+
+		i += length
+	}
+
+	weakness := weaknessNone
+	for kind, saved := range savings {
+		if saved > savings[weakness] {
+			weakness = kind
+		}
+	}
+
+	return bits, weakness
+}
+
+// approximates the number of characters an attacker has to try per position
+func charsetSize(runes []rune) float64 {
+	var lower, upper, digit, symbol, other bool
+
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0.0
+	for _, c := range []struct {
+		present bool
+		size    float64
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if c.present {
+			size += c.size
+		}
+	}
+
+	return math.Max(size, 2)
+}
+
+// splits the user's details into lowercase tokens of at least 3 characters
+func personalTokens(userInputs []string) [][]rune {
+	tokens := [][]rune{}
+
+	for _, input := range userInputs {
+		fields := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		// the whole input as well, e.g. a username like "dana-scully"
+		fields = append(fields, strings.ToLower(input))
+
+		for _, f := range fields {
+			if len([]rune(f)) >= 3 {
+				tokens = append(tokens, []rune(f))
+			}
+		}
+	}
+
+	return tokens
+}
+
+// undoes common character substitutions, keeping the length the same
+func unleet(s string) string {
+	return strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s").Replace(s)
+}
+
+func hasPrefix(s, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+
+	for i := range prefix {
+		if s[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// the number of times the first rune repeats at the start of s
+func repeatLength(s []rune) int {
+	n := 0
+	for n < len(s) && s[n] == s[0] {
+		n++
+	}
+
+	return n
+}
+
+// the length of the ascending or descending run at the start of s, like abc, 321 or qwerty
+func sequenceLength(s []rune) int {
+	longest := 0
+
+	for _, step := range []rune{1, -1} {
+		n := 1
+		for n < len(s) && s[n]-s[n-1] == step {
+			n++
+		}
+
+		if n > longest {
+			longest = n
+		}
+	}
+
+	for _, row := range keyboardRows {
+		for _, r := range []string{row, reverse(row)} {
+			i := strings.IndexRune(r, s[0])
+			if i < 0 {
+				continue
+			}
+
+			keys := []rune(r[i:])
+
+			n := 0
+			for n < len(s) && n < len(keys) && s[n] == keys[n] {
+				n++
+			}
+
+			if n > longest {
+				longest = n
+			}
+		}
+	}
+
+	return longest
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+
+	return set
+}
+
+// a file of sha-1 hashes of breached passwords in the format of the pwned passwords
+// downloads, one uppercase hex hash per line, optionally followed by ":<count>",
+// sorted by hash so it can be searched without loading it into memory
+type BreachedPasswords struct {
+	f    *os.File
+	size int64
+}
+
+func OpenBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &BreachedPasswords{f: f, size: info.Size()}, nil
+}
+
+func (b *BreachedPasswords) Close() error {
+	return b.f.Close()
+}
+
+// reports whether the sha-1 hash of the password is in the file, using a binary search
+// over byte offsets, each step reads the first full line at or after the middle offset
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := []byte(strings.ToUpper(hex.EncodeToString(sum[:])))
+
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start, line, err := b.lineAt(mid)
+		if err != nil {
+			return false, err
+		}
+
+		// no line starts between mid and hi, so the hash can only be before mid
+		if line == nil || start >= hi {
+			hi = mid
+			continue
+		}
+
+		hash, _, _ := bytes.Cut(bytes.TrimSpace(line), []byte(":"))
+
+		switch bytes.Compare(bytes.ToUpper(hash), target) {
+		case 0:
+			return true, nil
+		case -1:
+			lo = start + int64(len(line))
+		default:
+			hi = mid
+		}
+	}
+
+	return false, nil
+}
+
+var errBreachedLineTooLong = errors.New("validator: line in breached passwords file is too long")
+
+// returns the offset and contents (including the newline) of the first line starting
+// at or after offset, the line is nil if there is none
+func (b *BreachedPasswords) lineAt(offset int64) (int64, []byte, error) {
+	start := offset
+
+	// unless offset is the start of a line, skip the rest of the line it's in
+	if offset > 0 {
+		r := bufio.NewReader(io.NewSectionReader(b.f, offset-1, b.size-offset+1))
+
+		skipped, err := r.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, nil, nil
+			}
+			if errors.Is(err, bufio.ErrBufferFull) {
+				return 0, nil, errBreachedLineTooLong
+			}
+			return 0, nil, err
+		}
+
+		start = offset - 1 + int64(len(skipped))
+	}
+
+	if start >= b.size {
+		return start, nil, nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(b.f, start, b.size-start))
+
+	line, err := r.ReadSlice('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return 0, nil, errBreachedLineTooLong
+		}
+		return 0, nil, err
+	}
+
+	return start, append([]byte(nil), line...), nil
+}
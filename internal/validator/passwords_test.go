@@ -0,0 +1,84 @@
+package validator
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"gosnipit.ricci2511.dev/internal/assert"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := &PasswordPolicy{MinEntropy: DefaultMinPasswordEntropy}
+
+	tests := []struct {
+		name       string
+		password   string
+		wantReason string
+	}{
+		{"Strong password", "kestrel-vanilla-42", ""},
+		{"Random password", "x7k2p9qm", ""},
+		{"Common password", "Qwerty123", "one of the most commonly used passwords"},
+		{"Dictionary words", "Sunshine1!", "made of common words"},
+		{"Leetspeak", "$unsh1ne!", "made of common words"},
+		{"Sequence", "abcdefghijk12", "sequences like abc"},
+		{"Keyboard row", "lkjhgfdsa99", "sequences like abc"},
+		{"Repeats", "zzzzzzzzzzzz", "repeats the same character"},
+		{"Personal details", "danascully1994", "your name, username or email address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, err := policy.Check(tt.password, "Dana Scully", "dscully", "dana.scully@example.com")
+			assert.NilError(t, err)
+
+			if tt.wantReason == "" {
+				assert.Equal(t, reason, "")
+			} else {
+				assert.StringContains(t, reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	breached := []string{"hunter22", "correcthorse", "Tr0ub4dor&3", "kestrel-vanilla-42"}
+
+	// a file like the pwned passwords download, with lines that don't match anything in between
+	lines := []string{"0000000000000000000000000000000000000000:1", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1"}
+	for i, p := range breached {
+		sum := sha1.Sum([]byte(p))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":"+strings.Repeat("9", i+1))
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600)
+	assert.NilError(t, err)
+
+	b, err := OpenBreachedPasswords(path)
+	assert.NilError(t, err)
+	defer b.Close()
+
+	for _, p := range breached {
+		t.Run(p, func(t *testing.T) {
+			found, err := b.Contains(p)
+			assert.NilError(t, err)
+			assert.Equal(t, found, true)
+		})
+	}
+
+	found, err := b.Contains("not-breached-at-all")
+	assert.NilError(t, err)
+	assert.Equal(t, found, false)
+
+	policy := &PasswordPolicy{MinEntropy: DefaultMinPasswordEntropy, Breached: b}
+
+	reason, err := policy.Check("kestrel-vanilla-42")
+	assert.NilError(t, err)
+	assert.StringContains(t, reason, "appeared in a data breach")
+}
@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
 	"unicode/utf8"
@@ -9,6 +10,20 @@ import (
 // regex for email validation, compiled at startup to avoid re-parsing every time it's used
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// regex for snippet language hints like "go", "c++", "objective-c" or "f#"
+var LanguageRX = regexp.MustCompile(`^[a-zA-Z0-9+#._-]*$`)
+
+// regex for usernames, 3 to 30 lowercase letters, digits, hyphens or underscores
+// starting with a letter or digit so they're safe to use in urls as they are
+var UsernameRX = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{2,29}$`)
+
+// usernames that could be mistaken for the site itself or its staff
+var ReservedUsernames = []string{
+	"about", "account", "admin", "administrator", "api", "gosnipit", "help", "login",
+	"logout", "moderator", "root", "security", "signup", "snippets", "staff", "static",
+	"support", "system", "user", "users",
+}
+
 type Validator struct {
 	NonFieldErrors []string // validation errors not related to a specific field
 	FieldErrors    map[string]string
@@ -66,3 +81,28 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// returns true if every value is in the permittedValues slice
+func PermittedValues[T comparable](values []T, permittedValues ...T) bool {
+	for i := range values {
+		if !PermittedValue(values[i], permittedValues...) {
+			return false
+		}
+	}
+	return true
+}
+
+// returns true if the value is an absolute http or https url with a host
+func IsURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// returns true if the value is not one of the ReservedUsernames, ignoring case
+func NotReserved(value string) bool {
+	return !PermittedValue(strings.ToLower(value), ReservedUsernames...)
+}